@@ -124,3 +124,8 @@ func (db *TestDB) AddTestBrewsFromInfo(info ...brew.Info) error {
 
 	return nil
 }
+
+func (db *TestDB) AddTestCasksFromInfo(infos ...brew.CaskInfo) error {
+	cache := brew.Cache{DB: db.DB}
+	return cache.PutCaskInfo(infos)
+}
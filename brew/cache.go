@@ -13,6 +13,9 @@ import (
 
 type Cache struct {
 	DB *bolt.DB
+	// Called when a brew cannot be found, to refresh the Cache if it is stale.
+	// The lookup is tried once more if the Cache was refreshed.
+	OnMiss func(c *Cache, name string) (refreshed bool, err error)
 }
 
 // Create a BoltDB bucket for cask info, run the given command,
@@ -59,8 +62,94 @@ func (c *Cache) RefreshCasks(command *exec.Cmd) error {
 	return nil
 }
 
+// Create a BoltDB bucket for cask info, run the given command, parse the
+// response and store the info of every cask in the bucket.
+func (c *Cache) RefreshCaskInfo(command *exec.Cmd) error {
+	if runtime.GOOS == "linux" {
+		return nil
+	}
+
+	b, err := command.Output()
+	if err != nil {
+		return err
+	}
+
+	var allInfo struct {
+		Casks []CaskInfo `json:"casks"`
+	}
+
+	if err := json.Unmarshal(b, &allInfo); err != nil {
+		return err
+	}
+
+	return c.PutCaskInfo(allInfo.Casks)
+}
+
+// Store the info of casks in the cask info bucket by their full tokens. The
+// casks from taps other than homebrew/cask are indexed by their bare tokens in a
+// bucket of their own, which is rebuilt from scratch so that casks which have
+// since been dropped are forgotten. When casks from different taps share a
+// token, the bare token refers to the first of them by full token.
+func (c *Cache) PutCaskInfo(casks []CaskInfo) error {
+	return c.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte("cask_info"))
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+
+		for _, cask := range casks {
+			value, err := json.Marshal(cask)
+			if err != nil {
+				return err
+			}
+
+			if err := b.Put([]byte(cask.fullToken()), value); err != nil {
+				return err
+			}
+		}
+
+		return indexCaskTokens(tx)
+	})
+}
+
+// Rebuild the cask_token bucket from the info in the cask info bucket, mapping
+// the bare token of every cask from a tap other than homebrew/cask to its full
+// token.
+func indexCaskTokens(tx *bolt.Tx) error {
+	if tx.Bucket([]byte("cask_token")) != nil {
+		if err := tx.DeleteBucket([]byte("cask_token")); err != nil {
+			return err
+		}
+	}
+
+	tokens, err := tx.CreateBucket([]byte("cask_token"))
+	if err != nil {
+		return fmt.Errorf("create bucket: %s", err)
+	}
+
+	b := tx.Bucket([]byte("cask_info"))
+	if b == nil {
+		return nil
+	}
+
+	return b.ForEach(func(k, v []byte) error {
+		var cask CaskInfo
+		if err := json.Unmarshal(v, &cask); err != nil {
+			return err
+		}
+
+		if cask.fullToken() == cask.Token || b.Get([]byte(cask.Token)) != nil || tokens.Get([]byte(cask.Token)) != nil {
+			return nil
+		}
+
+		return tokens.Put([]byte(cask.Token), k)
+	})
+}
+
 // Create a BoltDB bucket for brew formulae info, run the given command,
-// parse the response and store it in the bucket.
+// parse the response and store it in the bucket. The aliases and old names of
+// every formula are indexed in a bucket of their own, which is rebuilt from
+// scratch so that names which have since been dropped are forgotten.
 func (c *Cache) Refresh(command *exec.Cmd) error {
 	b, err := command.Output()
 	if err != nil {
@@ -72,6 +161,16 @@ func (c *Cache) Refresh(command *exec.Cmd) error {
 		if err != nil {
 			return fmt.Errorf("create bucket: %s", err)
 		}
+
+		if tx.Bucket([]byte("alias")) != nil {
+			if err := tx.DeleteBucket([]byte("alias")); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.CreateBucket([]byte("alias")); err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
 		return nil
 	})
 
@@ -99,6 +198,13 @@ func (c *Cache) Refresh(command *exec.Cmd) error {
 				return err
 			}
 
+			aliases := tx.Bucket([]byte("alias"))
+			for _, name := range pkg.OtherNames() {
+				if err := aliases.Put([]byte(name), []byte(key)); err != nil {
+					return err
+				}
+			}
+
 			return nil
 		})
 
@@ -116,6 +222,10 @@ func (c Cache) FindCask(pkg string) (string, error) {
 
 	err := c.DB.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("cask"))
+		if b == nil {
+			return ErrCouldNotFindPackageInfo(pkg)
+		}
+
 		v := b.Get([]byte(pkg))
 
 		if v == nil {
@@ -134,14 +244,76 @@ func (c Cache) FindCask(pkg string) (string, error) {
 	return cask, nil
 }
 
-// Find a brew formula info in the BoltDB brew bucket.
+// Find a cask info in the BoltDB cask info bucket, by the full token of the cask
+// or by its bare token.
+func (c Cache) FindCaskInfo(token string) (CaskInfo, error) {
+	var info CaskInfo
+
+	err := c.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("cask_info"))
+		if b == nil {
+			return ErrCouldNotFindPackageInfo(token)
+		}
+
+		v := b.Get([]byte(token))
+
+		if tokens := tx.Bucket([]byte("cask_token")); v == nil && tokens != nil {
+			if fullToken := tokens.Get([]byte(token)); fullToken != nil {
+				v = b.Get(fullToken)
+			}
+		}
+
+		if v == nil {
+			return ErrCouldNotFindPackageInfo(token)
+		}
+
+		return json.Unmarshal(v, &info)
+	})
+
+	if err != nil {
+		return CaskInfo{}, err
+	}
+
+	return info, nil
+}
+
+// Find a brew formula info in the BoltDB brew bucket, by the full name of the
+// formula or by one of its aliases or old names.
 func (c Cache) Find(pkg string) (Info, error) {
+	info, err := c.find(pkg)
+	if err == nil || c.OnMiss == nil {
+		return info, err
+	}
+
+	refreshed, missErr := c.OnMiss(&c, pkg)
+	if missErr != nil {
+		return Info{}, missErr
+	}
+
+	if !refreshed {
+		return Info{}, err
+	}
+
+	return c.find(pkg)
+}
+
+func (c Cache) find(pkg string) (Info, error) {
 	var info Info
 
 	err := c.DB.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("brew"))
+		if b == nil {
+			return ErrCouldNotFindPackageInfo(pkg)
+		}
+
 		v := b.Get([]byte(pkg))
 
+		if aliases := tx.Bucket([]byte("alias")); v == nil && aliases != nil {
+			if name := aliases.Get([]byte(pkg)); name != nil {
+				v = b.Get(name)
+			}
+		}
+
 		if v == nil {
 			return ErrCouldNotFindPackageInfo(pkg)
 		}
@@ -2,9 +2,11 @@ package brew
 
 import (
 	"errors"
-	"regexp"
 	"sort"
 
+	"github.com/LGUG2Z/bfm/brewfile"
+	"github.com/LGUG2Z/bfm/diagnostics"
+	"github.com/LGUG2Z/bfm/graph"
 	. "github.com/LGUG2Z/bfm/helpers"
 )
 
@@ -13,99 +15,176 @@ type Map map[string]Entry
 type CacheMap struct {
 	Map   Map
 	Cache *Cache
+
+	// When set, the dependencies of installed brews are the runtime dependencies
+	// recorded for their kegs rather than the dependencies of their formulae.
+	Installed bool
+
+	// The casks in the Brewfile and the casks required by its brews and casks.
+	// Casks are only resolved when this is set.
+	Casks Map
 }
 
 // Creates a CacheMap with filled info from the BoltDB cache based on
 // the packages in a Brewfile. Dependencies not resolved at this stage.
-func (c CacheMap) FromPackages(packages []string) error {
-	quotesRegexp := regexp.MustCompile(`'\S+'`)
-	argsRegexp := regexp.MustCompile(`\[.*\]`)
-	restartRegexp := regexp.MustCompile(`restart_service: (:changed|true)`)
-	restartBehaviourRegexp := regexp.MustCompile(`(:changed|true)`)
+// Packages which cannot be found or read are reported together, with their
+// positions in the Brewfile.
+func (c CacheMap) FromPackages(packages []brewfile.Entry) error {
+	var errs diagnostics.List
 
 	for _, p := range packages {
-		match := quotesRegexp.FindString(p)
-		pkg := match[1 : len(match)-1]
-
-		info, err := c.Cache.Find(pkg)
+		info, err := c.Cache.Find(p.Name)
 		if err != nil {
-			return err
+			errs.Add(p.Pos, err)
+			continue
 		}
 
 		e := Entry{}
-		e.FromInfo(info)
-
-		args := argsRegexp.FindString(p)
-		if len(args) > 0 {
-			matches := quotesRegexp.FindAllString(args, -1)
-			for _, m := range matches {
-				arg := m[1 : len(m)-1]
-				e.Args = append(e.Args, arg)
-			}
-		}
+		c.fromInfo(&e, info)
 
-		restartService := restartRegexp.FindString(p)
-		if len(restartService) > 0 {
-			e.RestartService = restartBehaviourRegexp.FindString(restartService)
+		if err := e.FromBrewfileEntry(p); err != nil {
+			errs.Add(p.Pos, err)
+			continue
 		}
 
-		c.Map[info.FullName] = e
+		c.Map.put(e)
 	}
 
-	return nil
+	return errs.Err()
+}
+
+// Stores an entry read from a Brewfile in a Map. A package listed in blocks with
+// different conditions, or in different Brewfiles, keeps the entries after the
+// first as copies of it, so that none of them is lost.
+func (m Map) put(e Entry) {
+	first, present := m[e.Name]
+	if !present {
+		m[e.Name] = e
+		return
+	}
+
+	e.Copies = nil
+	if first.sameScope(e) {
+		e.Copies = first.Copies
+		m[e.Name] = e
+		return
+	}
+
+	for i, c := range first.Copies {
+		if c.sameScope(e) {
+			first.Copies[i] = e
+			m[e.Name] = first
+			return
+		}
+	}
+
+	first.Copies = append(first.Copies, e)
+	m[e.Name] = first
 }
 
 // Resolves which dependencies are required, recommended, optional or build dependencies
-// for otherpackages in the Brewfile, based on the level given by the user.
+// for otherpackages in the Brewfile, based on the level given by the user and the args
+// of each package. Dependencies which cannot be found are reported together, at the
+// positions of the packages depending on them, as are dependency cycles.
 func (c CacheMap) ResolveDependencyMap(level int) error {
-	if level >= Required {
-		for _, b := range c.Map {
-			if len(b.RequiredDependencies) > 0 {
-				for _, d := range b.RequiredDependencies {
-					if err := c.addDependency(d, b.Name, RequiredDependency); err != nil {
-						return err
-					}
+	var errs diagnostics.List
+	g := c.graph()
+
+	for _, dependencyType := range dependencyTypes {
+		for _, name := range c.names() {
+			b := c.Map[name]
+			for _, s := range b.Scopes() {
+				for _, d := range s.Dependencies(dependencyType, level) {
+					errs.Add(s.Pos, c.addDependency(g, d, b.Name, dependencyType))
 				}
 			}
 		}
 	}
 
-	if level >= Recommended {
-		for _, b := range c.Map {
-			if len(b.RecommendedDependencies) > 0 {
-				for _, d := range b.RecommendedDependencies {
-					if err := c.addDependency(d, b.Name, RecommendedDependency); err != nil {
-						return err
-					}
-				}
-			}
-		}
+	errs.Add(brewfile.Pos{}, c.resolveCasks(g))
+	c.resolveScopes()
+	return errs.Err()
+}
+
+// Returns the entry of a brew in the CacheMap, or of a cask if no brew has the
+// given name, and whether either is in the CacheMap.
+func (c CacheMap) Lookup(name string) (Entry, bool) {
+	if e, present := c.Map[name]; present {
+		return e, true
 	}
 
-	if level >= Optional {
-		for _, b := range c.Map {
-			if len(b.OptionalDependencies) > 0 {
-				for _, d := range b.OptionalDependencies {
-					if err := c.addDependency(d, b.Name, OptionalDependency); err != nil {
-						return err
-					}
-				}
-			}
-		}
+	e, present := c.Casks[name]
+	return e, present
+}
+
+// Returns the names of the packages in the CacheMap, sorted, so that packages
+// added while going through them are left out and the order is the same every
+// time.
+func (c CacheMap) names() []string {
+	return c.Map.names()
+}
+
+// Returns the names of the packages in a Map, sorted.
+func (m Map) names() []string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
 	}
 
-	if level >= Build {
-		for _, b := range c.Map {
-			if len(b.BuildDependencies) > 0 {
-				for _, d := range b.BuildDependencies {
-					if err := c.addDependency(d, b.Name, BuildDependency); err != nil {
-						return err
-					}
-				}
+	sort.Strings(names)
+	return names
+}
+
+// Compares the annotations of the brews and casks in a Brewfile with the
+// dependencies resolved by ResolveDependencyMap, reporting annotations which are
+// stale or have been edited by hand and dependencies which are not annotated, at
+// the positions of the packages in the Brewfile.
+func (c CacheMap) CheckAnnotations(packages []brewfile.Entry, layout *brewfile.Layout) error {
+	if layout == nil {
+		layout = brewfile.DefaultLayout()
+	}
+
+	var errs diagnostics.List
+
+	for _, p := range packages {
+		m := c.Map
+		if p.Type == "cask" {
+			m = c.Casks
+		}
+
+		e, present := m[p.Name]
+		if !present && p.Type != "cask" {
+			info, err := c.Cache.Find(p.Name)
+			if err != nil {
+				errs.Add(p.Pos, err)
+				continue
 			}
+
+			e = c.Map[info.FullName]
+		}
+
+		expected, err := e.Annotation(layout)
+		if err != nil {
+			return err
+		}
+
+		if p.Annotation == expected {
+			continue
+		}
+
+		switch found := layout.ParseAnnotation(p.Annotation); {
+		case len(p.Annotation) < 1:
+			errs.Add(p.Pos, ErrMissingAnnotation(p.Name, expected))
+		case len(expected) < 1:
+			errs.Add(p.Pos, ErrAnnotatedPrimary(p.Name, p.Annotation))
+		case found.Equal(e.Dependents()):
+			errs.Add(p.Pos, ErrHandEditedAnnotation(p.Name, expected, p.Annotation))
+		default:
+			errs.Add(p.Pos, ErrStaleAnnotation(p.Name, expected, p.Annotation))
 		}
 	}
-	return nil
+
+	return errs.Err()
 }
 
 // Add an entry to the CacheMap and update the dependency map.
@@ -115,214 +194,243 @@ func (c CacheMap) Add(entry Entry, level int) error {
 		return err
 	}
 
-	entry.FromInfo(info)
+	c.fromInfo(&entry, info)
 	c.Map[entry.Name] = entry
+	g := c.graph()
 
-	if level >= Required {
-		for _, dep := range entry.RequiredDependencies {
-			if err := c.addDependency(dep, entry.Name, RequiredDependency); err != nil {
-				return err
-			}
-		}
-	}
-	if level >= Recommended {
-		for _, dep := range entry.RecommendedDependencies {
-			if err := c.addDependency(dep, entry.Name, RecommendedDependency); err != nil {
-				return err
-			}
-		}
-	}
-	if level >= Optional {
-		for _, dep := range entry.OptionalDependencies {
-			if err := c.addDependency(dep, entry.Name, OptionalDependency); err != nil {
+	for _, dependencyType := range dependencyTypes {
+		for _, dep := range entry.Dependencies(dependencyType, level) {
+			if err := c.addDependency(g, dep, entry.Name, dependencyType); err != nil {
 				return err
 			}
 		}
 	}
-	if level >= Build {
-		for _, dep := range entry.BuildDependencies {
-			if err := c.addDependency(dep, entry.Name, BuildDependency); err != nil {
-				return err
-			}
-		}
+
+	if err := c.resolveCasks(g); err != nil {
+		return err
 	}
 
+	c.resolveScopes()
 	return nil
 }
 
-// Remove an entry from the CacheMap and update the dependency map.
+// Remove an entry from the CacheMap and update the dependency map. Dependencies
+// left without dependents are removed too, unless they are explicit.
 func (c CacheMap) Remove(name string, level int) error {
 	if _, present := c.Map[name]; !present {
 		return errors.New("Nothing to remove.")
 	}
 
 	entry := c.Map[name]
+	delete(c.Map, name)
 
-	if level >= Required {
-		for _, dep := range entry.RequiredDependencies {
-			c.removeDependency(dep, name, RequiredDependency)
-		}
-
-		for _, dep := range entry.RequiredDependencies {
-			if len(c.Map[dep].RequiredBy) < 1 {
-				if err := c.Remove(c.Map[dep].Name, level); err != nil {
-					return err
+	for _, dependencyType := range dependencyTypes {
+		var dependencies []string
+		for _, s := range entry.Scopes() {
+			for _, dep := range s.Dependencies(dependencyType, level) {
+				if !Contains(dependencies, dep) {
+					dependencies = append(dependencies, dep)
 				}
 			}
 		}
-	}
-	if level >= Recommended {
-		for _, dep := range entry.RecommendedDependencies {
-			c.removeDependency(dep, name, RecommendedDependency)
-		}
 
-		for _, dep := range entry.RecommendedDependencies {
-			if len(c.Map[dep].RequiredBy) < 1 {
-				if err := c.Remove(c.Map[dep].Name, level); err != nil {
-					return err
-				}
-			}
-		}
-	}
-	if level >= Optional {
-		for _, dep := range entry.OptionalDependencies {
-			c.removeDependency(dep, name, OptionalDependency)
+		for _, dep := range dependencies {
+			c.removeDependency(dep, name, dependencyType)
 		}
 
-		for _, dep := range entry.OptionalDependencies {
-			if len(c.Map[dep].RequiredBy) < 1 {
-				if err := c.Remove(c.Map[dep].Name, level); err != nil {
+		for _, dep := range dependencies {
+			if d, present := c.Map[dep]; present && len(d.RequiredBy) < 1 && !d.Explicit {
+				if err := c.Remove(d.Name, level); err != nil {
 					return err
 				}
 			}
 		}
 	}
-	if level >= Build {
-		for _, dep := range entry.BuildDependencies {
-			c.removeDependency(dep, name, BuildDependency)
-		}
 
-		for _, dep := range entry.BuildDependencies {
-			if len(c.Map[dep].RequiredBy) < 1 {
-				if err := c.Remove(c.Map[dep].Name, level); err != nil {
-					return err
-				}
-			}
+	for _, cask := range entry.CaskDependencies {
+		if err := c.removeCaskDependency(cask, name, level); err != nil {
+			return err
 		}
 	}
 
-	delete(c.Map, name)
+	c.resolveScopes()
 	return nil
 }
 
-// Map one package to be a dependency of another.
-func (c CacheMap) addDependency(req, by string, dependencyType int) error {
-	var e Entry
-
-	if _, present := c.Map[req]; !present {
-		info, err := c.Cache.Find(req)
-		if err != nil {
-			return err
-		}
+// Fills an entry with the info of its package. In installed mode, the
+// dependencies of an installed brew are the runtime dependencies recorded for its
+// keg, without those pulled in through its other runtime dependencies.
+func (c CacheMap) fromInfo(e *Entry, info Info) {
+	e.FromInfo(info)
 
-		e = Entry{}
-		e.FromInfo(info)
-	} else {
-		e = c.Map[req]
+	if !c.Installed {
+		return
 	}
 
-	switch dependencyType {
-	case RequiredDependency:
-		if !Contains(e.RequiredBy, by) {
-			e.RequiredBy = append(e.RequiredBy, by)
-			sort.Strings(e.RequiredBy)
-		}
+	runtime, recorded := info.RuntimeDependencies()
+	if !recorded {
+		return
+	}
 
-		c.Map[e.Name] = e
+	e.DetermineInstalledDependencies(info, c.directDependencies(runtime))
+}
 
-		if len(e.RequiredDependencies) > 0 {
-			for _, d := range e.RequiredDependencies {
-				if err := c.addDependency(d, e.Name, RequiredDependency); err != nil {
-					return err
-				}
+// Returns the runtime dependencies which are not a dependency of another of the
+// runtime dependencies.
+func (c CacheMap) directDependencies(runtime []string) []string {
+	var direct []string
+	for _, d := range runtime {
+		indirect := false
+		for _, other := range runtime {
+			if other != d && Contains(c.dependencyClosure(other), d) {
+				indirect = true
+				break
 			}
 		}
-	case RecommendedDependency:
-		if !Contains(e.RecommendedFor, by) {
-			e.RecommendedFor = append(e.RecommendedFor, by)
-			sort.Strings(e.RecommendedFor)
+
+		if !indirect {
+			direct = append(direct, d)
 		}
+	}
 
-		c.Map[e.Name] = e
+	return direct
+}
 
-		if len(e.RecommendedDependencies) > 0 {
-			for _, d := range e.RecommendedDependencies {
-				if err := c.addDependency(d, e.Name, RecommendedDependency); err != nil {
-					return err
-				}
-			}
-		}
-	case OptionalDependency:
-		if !Contains(e.OptionalFor, by) {
-			e.OptionalFor = append(e.OptionalFor, by)
-			sort.Strings(e.OptionalFor)
-		}
+// Returns the runtime dependencies recorded for an installed package, or the
+// required dependencies of its formula if none are recorded.
+func (c CacheMap) dependencyClosure(name string) []string {
+	info, err := c.Cache.Find(name)
+	if err != nil {
+		return nil
+	}
 
-		c.Map[e.Name] = e
+	if runtime, recorded := info.RuntimeDependencies(); recorded {
+		return runtime
+	}
 
-		if len(e.OptionalDependencies) > 0 {
-			for _, d := range e.OptionalDependencies {
-				if err := c.addDependency(d, e.Name, OptionalDependency); err != nil {
-					return err
-				}
-			}
-		}
-	case BuildDependency:
-		if !Contains(e.BuildOf, by) {
-			e.BuildOf = append(e.BuildOf, by)
-			sort.Strings(e.BuildOf)
+	return info.Dependencies
+}
+
+// Returns the entry of a package in the CacheMap, or a new entry filled with the
+// info of the package if it is not in the CacheMap yet.
+func (c CacheMap) entry(name string) (Entry, error) {
+	if e, present := c.Map[name]; present {
+		return e, nil
+	}
+
+	info, err := c.Cache.Find(name)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	e := Entry{}
+	c.fromInfo(&e, info)
+	return e, nil
+}
+
+// Returns a dependency graph of the packages in the cache, in which the edges of
+// each type leaving a package are the dependencies of that type of its formula.
+func (c CacheMap) graph() *graph.Graph {
+	return graph.New(func(name string, kind graph.Kind) ([]string, error) {
+		e, err := c.entry(name)
+		if err != nil {
+			return nil, err
 		}
 
-		c.Map[e.Name] = e
+		return e.formulaDependencies(int(kind)), nil
+	})
+}
 
-		if len(e.BuildDependencies) > 0 {
-			for _, d := range e.BuildDependencies {
-				if err := c.addDependency(d, e.Name, BuildDependency); err != nil {
-					return err
-				}
-			}
+// Map one package to be a dependency of another, along with the dependencies of
+// the same type of that package, walking the packages reachable from it once.
+func (c CacheMap) addDependency(g *graph.Graph, req, by string, dependencyType int) error {
+	return g.Walk(by, req, graph.Kind(dependencyType), func(by, req string) error {
+		e, err := c.entry(req)
+		if err != nil {
+			return err
 		}
-	}
 
-	return nil
+		e.addDependent(by, dependencyType)
+		c.Map[e.Name] = e
+		return nil
+	})
 }
 
 // Unmap a package as a dependency of another upon removal of that package.
 func (c CacheMap) removeDependency(req, by string, dependencyType int) {
-	b := c.Map[req]
+	b, present := c.Map[req]
+	if !present {
+		return
+	}
 
-	switch dependencyType {
-	case RequiredDependency:
-		if Contains(b.RequiredBy, by) {
-			b.RequiredBy = Remove(b.RequiredBy, by)
-			sort.Strings(b.RequiredBy)
+	if dependents := b.dependents(dependencyType); dependents != nil {
+		*dependents = Remove(*dependents, by)
+	}
+
+	c.Map[b.Name] = b
+}
+
+// Scopes every dependency to the condition and Brewfile of the packages depending
+// on it. A dependency of packages in blocks with different conditions, or of a
+// package outside of any block, is not scoped to a condition, and a dependency of
+// packages in different Brewfiles is written in the Brewfile including the others.
+// The copies of a package listed in more than one block are all taken into
+// account. Casks are scoped the same way as brews.
+func (c CacheMap) resolveScopes() {
+	resolved := make(map[string]bool)
+
+	var resolve func(name string, visiting map[string]bool) Entry
+	resolve = func(name string, visiting map[string]bool) Entry {
+		m := c.Map
+		if _, present := m[name]; !present && c.Casks != nil {
+			m = c.Casks
 		}
-	case RecommendedDependency:
-		if Contains(b.RecommendedFor, by) {
-			b.RecommendedFor = Remove(b.RecommendedFor, by)
-			sort.Strings(b.RecommendedFor)
+
+		e := m[name]
+		if resolved[name] || visiting[name] {
+			return e
 		}
-	case OptionalDependency:
-		if Contains(b.OptionalFor, by) {
-			b.OptionalFor = Remove(b.OptionalFor, by)
-			sort.Strings(b.OptionalFor)
+
+		visiting[name] = true
+
+		var dependents []string
+		dependents = append(dependents, e.RequiredBy...)
+		dependents = append(dependents, e.RecommendedFor...)
+		dependents = append(dependents, e.OptionalFor...)
+		dependents = append(dependents, e.BuildOf...)
+
+		var scopes []Entry
+		for _, d := range dependents {
+			dependent := resolve(d, visiting)
+			scopes = append(scopes, dependent.Scopes()...)
 		}
-	case BuildDependency:
-		if Contains(b.BuildOf, by) {
-			b.BuildOf = Remove(b.BuildOf, by)
-			sort.Strings(b.BuildOf)
+
+		if len(scopes) > 0 {
+			condition, file := scopes[0].Condition, scopes[0].File
+
+			for _, dependent := range scopes[1:] {
+				if dependent.Condition != condition {
+					condition = ""
+				}
+
+				if dependent.File != file {
+					file = ""
+				}
+			}
+
+			e.Condition, e.File = condition, file
+			m[name] = e
 		}
+
+		resolved[name] = true
+		return e
 	}
 
-	c.Map[b.Name] = b
+	for name := range c.Map {
+		resolve(name, make(map[string]bool))
+	}
+
+	for name := range c.Casks {
+		resolve(name, make(map[string]bool))
+	}
 }
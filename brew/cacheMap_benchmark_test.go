@@ -0,0 +1,129 @@
+package brew_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/LGUG2Z/bfm/brew"
+	"github.com/LGUG2Z/bfm/brewfile"
+	. "github.com/LGUG2Z/bfm/helpers"
+)
+
+// Fills a cache with 500 brews depending on a stack of libraries in which every
+// library depends on the two below it, so that the libraries are reached through
+// many different paths, as openssl and readline are in a real Brewfile.
+func benchmarkCache(b *testing.B) (brew.Cache, []brewfile.Entry, func()) {
+	dir, err := ioutil.TempDir("", "bfm")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	db, err := NewTestDB(filepath.Join(dir, "benchmark.bolt"))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	const libraries = 20
+	var info []brew.Info
+	for i := 0; i < libraries; i++ {
+		var dependencies []string
+		for _, d := range []int{i + 1, i + 2} {
+			if d < libraries {
+				dependencies = append(dependencies, fmt.Sprintf("lib%d", d))
+			}
+		}
+
+		info = append(info, brew.Info{FullName: fmt.Sprintf("lib%d", i), Dependencies: dependencies})
+	}
+
+	var packages []brewfile.Entry
+	for i := 0; i < 500; i++ {
+		name := fmt.Sprintf("brew%d", i)
+		info = append(info, brew.Info{FullName: name, Dependencies: []string{fmt.Sprintf("lib%d", i%3)}})
+		packages = append(packages, brewfile.Entry{Type: "brew", Name: name})
+	}
+
+	if err := db.AddTestBrewsFromInfo(info...); err != nil {
+		b.Fatal(err)
+	}
+
+	return brew.Cache{DB: db.DB}, packages, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func BenchmarkResolveDependencyMap(b *testing.B) {
+	cache, packages, cleanup := benchmarkCache(b)
+	defer cleanup()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cacheMap := brew.CacheMap{Cache: &cache, Map: make(brew.Map)}
+		if err := cacheMap.FromPackages(packages); err != nil {
+			b.Fatal(err)
+		}
+
+		if err := cacheMap.ResolveDependencyMap(brew.Required); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Resolves the required dependencies of the same brews the way bfm did before
+// walking a dependency graph, as a baseline for BenchmarkResolveDependencyMap.
+func BenchmarkResolveDependencyMapRecursively(b *testing.B) {
+	cache, packages, cleanup := benchmarkCache(b)
+	defer cleanup()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cacheMap := brew.CacheMap{Cache: &cache, Map: make(brew.Map)}
+		if err := cacheMap.FromPackages(packages); err != nil {
+			b.Fatal(err)
+		}
+
+		for _, p := range packages {
+			e := cacheMap.Map[p.Name]
+			for _, d := range e.RequiredDependencies {
+				if err := addDependencyRecursively(cacheMap, d, e.Name); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+// Maps one package to be a required dependency of another, then maps its own
+// required dependencies again, following every path through the dependencies
+// rather than walking each package once.
+func addDependencyRecursively(c brew.CacheMap, req, by string) error {
+	e, present := c.Map[req]
+	if !present {
+		info, err := c.Cache.Find(req)
+		if err != nil {
+			return err
+		}
+
+		e.FromInfo(info)
+	}
+
+	if !Contains(e.RequiredBy, by) {
+		e.RequiredBy = append(e.RequiredBy, by)
+		sort.Strings(e.RequiredBy)
+	}
+
+	c.Map[e.Name] = e
+
+	for _, d := range e.RequiredDependencies {
+		if err := addDependencyRecursively(c, d, e.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -3,9 +3,13 @@ package brew_test
 import (
 	. "github.com/LGUG2Z/bfm/brew"
 
+	"encoding/json"
 	"fmt"
 	"os"
 
+	"github.com/LGUG2Z/bfm/brewfile"
+	"github.com/LGUG2Z/bfm/graph"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -46,7 +50,7 @@ var _ = Describe("CacheMap", func() {
 	Describe("Initialising with a list of package names", func() {
 		It("Should create an entry in the map for every package which has info in the cache", func() {
 			Expect(db.AddTestBrews("vim", "emacs")).To(Succeed())
-			packages := []string{"brew 'vim'", "brew 'emacs'"}
+			packages := []brewfile.Entry{{Type: "brew", Name: "vim"}, {Type: "brew", Name: "emacs"}}
 
 			cacheMap.FromPackages(packages)
 			vimEntry := Entry{Name: "vim"}
@@ -58,13 +62,34 @@ var _ = Describe("CacheMap", func() {
 
 		It("Should not create entries in the map for packages which have no info in the cache", func() {
 			Expect(db.AddTestBrews("vim")).To(Succeed())
-			packages := []string{"brew 'vim'", "brew 'emacs'"}
+			packages := []brewfile.Entry{{Type: "brew", Name: "vim"}, {Type: "brew", Name: "emacs"}}
 			cacheMap.FromPackages(packages)
 
 			vimEntry := Entry{Name: "vim"}
 			Expect(cacheMap.Map).To(HaveKeyWithValue("vim", vimEntry))
 			Expect(cacheMap.Map).ToNot(HaveKey("emacs"))
 		})
+
+		It("Should read args and restart_service and carry other options and comments through", func() {
+			Expect(db.AddTestBrews("vim")).To(Succeed())
+
+			file, err := brewfile.Parse("Brewfile", []byte(`brew "vim", args: ["HEAD"], future_option: :yes, restart_service: :changed # editor`))
+			Expect(err).ToNot(HaveOccurred())
+
+			var packages brewfile.Packages
+			packages.KeepComments = true
+			packages.FromFile(file)
+
+			Expect(cacheMap.FromPackages(packages.Brew)).To(Succeed())
+
+			vim := cacheMap.Map["vim"]
+			Expect(vim.Args).To(Equal([]string{"HEAD"}))
+			Expect(vim.RestartService).To(Equal(":changed"))
+			Expect(vim.Options.Keys()).To(Equal([]string{"future_option"}))
+			Expect(vim.Comment).To(Equal("editor"))
+
+			Expect(vim.Format(nil)).To(Equal("brew 'vim', args: ['HEAD'], restart_service: :changed, future_option: :yes # editor"))
+		})
 	})
 
 	Describe("Populated with packages and with a Cache", func() {
@@ -74,13 +99,465 @@ var _ = Describe("CacheMap", func() {
 				Info{FullName: "python"},
 			)).To(Succeed())
 
-			packages := []string{"brew 'vim'", "brew 'python'"}
+			packages := []brewfile.Entry{{Type: "brew", Name: "vim"}, {Type: "brew", Name: "python"}}
 			cacheMap.FromPackages(packages)
 
 			cacheMap.ResolveDependencyMap(Required)
 
 			Expect(cacheMap.Map["python"].RequiredBy).To(ContainElement("vim"))
 		})
+
+		It("Should record every package depending on a dependency shared through several paths", func() {
+			Expect(db.AddTestBrewsFromInfo(
+				Info{FullName: "neovim", Dependencies: []string{"gettext", "libuv"}},
+				Info{FullName: "gettext", Dependencies: []string{"readline"}},
+				Info{FullName: "libuv", Dependencies: []string{"readline"}},
+				Info{FullName: "readline", Dependencies: []string{"ncurses"}},
+				Info{FullName: "ncurses"},
+			)).To(Succeed())
+
+			Expect(cacheMap.Add(Entry{Name: "neovim"}, Required)).To(Succeed())
+
+			Expect(cacheMap.Map["readline"].RequiredBy).To(Equal([]string{"gettext", "libuv"}))
+			Expect(cacheMap.Map["ncurses"].RequiredBy).To(Equal([]string{"readline"}))
+
+			Expect(cacheMap.Remove("neovim", Required)).To(Succeed())
+			Expect(cacheMap.Map).To(BeEmpty())
+		})
+
+		It("Should keep explicit dependencies and their own dependencies when removing a package", func() {
+			Expect(db.AddTestBrewsFromInfo(
+				Info{FullName: "curl", Dependencies: []string{"openssl"}},
+				Info{FullName: "openssl", Dependencies: []string{"ca-certificates"}},
+				Info{FullName: "ca-certificates"},
+			)).To(Succeed())
+
+			Expect(cacheMap.FromPackages([]brewfile.Entry{
+				{Type: "brew", Name: "curl"},
+				{Type: "brew", Name: "openssl", Explicit: true},
+			})).To(Succeed())
+			Expect(cacheMap.ResolveDependencyMap(Required)).To(Succeed())
+
+			Expect(cacheMap.Remove("curl", Required)).To(Succeed())
+			Expect(cacheMap.Map).To(HaveLen(2))
+			Expect(cacheMap.Map["openssl"].RequiredBy).To(BeEmpty())
+			Expect(cacheMap.Map["ca-certificates"].RequiredBy).To(Equal([]string{"openssl"}))
+		})
+
+		It("Should find every chain of dependencies leading from a primary brew to a package", func() {
+			Expect(db.AddTestBrewsFromInfo(
+				Info{FullName: "neovim", Dependencies: []string{"gettext", "libuv"}, BuildDependencies: []string{"libuv"}},
+				Info{FullName: "weechat", Dependencies: []string{"gettext"}},
+				Info{FullName: "gettext", Dependencies: []string{"readline"}},
+				Info{FullName: "libuv"},
+				Info{FullName: "readline"},
+			)).To(Succeed())
+
+			packages := []brewfile.Entry{{Type: "brew", Name: "neovim"}, {Type: "brew", Name: "weechat"}}
+			Expect(cacheMap.FromPackages(packages)).To(Succeed())
+			Expect(cacheMap.ResolveDependencyMap(Build)).To(Succeed())
+
+			Expect(cacheMap.Chains("readline")).To(Equal([]Chain{
+				{{Name: "neovim"}, {Name: "gettext", DependencyType: RequiredDependency}, {Name: "readline", DependencyType: RequiredDependency}},
+				{{Name: "weechat"}, {Name: "gettext", DependencyType: RequiredDependency}, {Name: "readline", DependencyType: RequiredDependency}},
+			}))
+
+			Expect(cacheMap.Chains("libuv")).To(HaveLen(1))
+			Expect(cacheMap.Chains("libuv")[0].String()).To(Equal("neovim -> libuv (build)"))
+			Expect(cacheMap.Chains("neovim")).To(BeEmpty())
+		})
+
+		It("Should export the resolved dependencies as a graph, rooted at a package or limited to a depth", func() {
+			Expect(db.AddTestBrewsFromInfo(
+				Info{FullName: "neovim", Dependencies: []string{"gettext"}},
+				Info{FullName: "weechat", Dependencies: []string{"gettext"}},
+				Info{FullName: "gettext", Dependencies: []string{"tap/readline"}},
+				Info{FullName: "tap/readline"},
+			)).To(Succeed())
+
+			packages := []brewfile.Entry{{Type: "brew", Name: "neovim"}, {Type: "brew", Name: "weechat"}}
+			Expect(cacheMap.FromPackages(packages)).To(Succeed())
+			Expect(cacheMap.ResolveDependencyMap(Required)).To(Succeed())
+
+			export := cacheMap.Export("", 0)
+			Expect(export.Nodes).To(Equal([]graph.Node{
+				{Name: "gettext", Tap: "homebrew/core", Type: "brew"},
+				{Name: "neovim", Tap: "homebrew/core", Type: "brew", Primary: true},
+				{Name: "tap/readline", Tap: "tap", Type: "brew"},
+				{Name: "weechat", Tap: "homebrew/core", Type: "brew", Primary: true},
+			}))
+			Expect(export.Edges).To(Equal([]graph.Edge{
+				{From: "gettext", To: "tap/readline", Kind: graph.Required},
+				{From: "neovim", To: "gettext", Kind: graph.Required},
+				{From: "weechat", To: "gettext", Kind: graph.Required},
+			}))
+
+			export = cacheMap.Export("neovim", 1)
+			Expect(export.Nodes).To(HaveLen(2))
+			Expect(export.Edges).To(Equal([]graph.Edge{{From: "neovim", To: "gettext", Kind: graph.Required}}))
+		})
+
+		It("Should write the resolved dependencies as a tree, writing repeated subtrees once", func() {
+			Expect(db.AddTestBrewsFromInfo(
+				Info{FullName: "neovim", Dependencies: []string{"gettext", "libuv"}, BuildDependencies: []string{"libuv"}},
+				Info{FullName: "weechat", Dependencies: []string{"gettext"}},
+				Info{FullName: "gettext", Dependencies: []string{"readline"}},
+				Info{FullName: "libuv"},
+				Info{FullName: "readline"},
+			)).To(Succeed())
+
+			packages := []brewfile.Entry{{Type: "brew", Name: "neovim"}, {Type: "brew", Name: "weechat"}}
+			Expect(cacheMap.FromPackages(packages)).To(Succeed())
+			Expect(cacheMap.ResolveDependencyMap(Build)).To(Succeed())
+
+			Expect(cacheMap.Tree("", false)).To(Equal(`neovim
+├── gettext (required)
+│   └── readline (required)
+└── libuv (build)
+weechat
+└── gettext (required) (see above)
+`))
+
+			Expect(cacheMap.Tree("", true)).To(Equal(`libuv
+└── neovim (build)
+readline
+└── gettext (required)
+    ├── neovim (required)
+    └── weechat (required)
+`))
+
+			Expect(cacheMap.Tree("gettext", false)).To(Equal("gettext\n└── readline (required)\n"))
+		})
+
+		It("Should report dependency cycles instead of following them", func() {
+			Expect(db.AddTestBrewsFromInfo(
+				Info{FullName: "tap/a", Dependencies: []string{"tap/b"}},
+				Info{FullName: "tap/b", Dependencies: []string{"tap/c"}},
+				Info{FullName: "tap/c", Dependencies: []string{"tap/b"}},
+			)).To(Succeed())
+
+			packages := []brewfile.Entry{{Type: "brew", Name: "tap/a"}}
+			Expect(cacheMap.FromPackages(packages)).To(Succeed())
+
+			err := cacheMap.ResolveDependencyMap(Required)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Dependency cycle: tap/b -> tap/c -> tap/b."))
+		})
+	})
+
+	Describe("Populated with packages in conditional blocks", func() {
+		BeforeEach(func() {
+			Expect(db.AddTestBrewsFromInfo(
+				Info{FullName: "mpv", Dependencies: []string{"ffmpeg"}},
+				Info{FullName: "vlc", Dependencies: []string{"ffmpeg", "lua"}},
+				Info{FullName: "ffmpeg", Dependencies: []string{"x264"}},
+				Info{FullName: "lua"},
+				Info{FullName: "x264"},
+			)).To(Succeed())
+		})
+
+		It("Should scope dependencies to the condition of the packages depending on them", func() {
+			packages := []brewfile.Entry{{Type: "brew", Name: "mpv", Condition: "if OS.mac?"}}
+			Expect(cacheMap.FromPackages(packages)).To(Succeed())
+			Expect(cacheMap.ResolveDependencyMap(Required)).To(Succeed())
+
+			Expect(cacheMap.Map["ffmpeg"].Condition).To(Equal("if OS.mac?"))
+			Expect(cacheMap.Map["x264"].Condition).To(Equal("if OS.mac?"))
+		})
+
+		It("Should not scope dependencies of packages with different conditions", func() {
+			packages := []brewfile.Entry{{Type: "brew", Name: "mpv", Condition: "if OS.mac?"}, {Type: "brew", Name: "vlc", Condition: "if OS.linux?"}}
+			Expect(cacheMap.FromPackages(packages)).To(Succeed())
+			Expect(cacheMap.ResolveDependencyMap(Required)).To(Succeed())
+
+			Expect(cacheMap.Map["ffmpeg"].Condition).To(Equal(""))
+			Expect(cacheMap.Map["x264"].Condition).To(Equal(""))
+			Expect(cacheMap.Map["lua"].Condition).To(Equal("if OS.linux?"))
+		})
+
+		It("Should keep a package listed in blocks with different conditions in each of them", func() {
+			packages := []brewfile.Entry{{Type: "brew", Name: "mpv", Condition: "if OS.mac?"}, {Type: "brew", Name: "mpv", Condition: "if OS.linux?"}}
+			Expect(cacheMap.FromPackages(packages)).To(Succeed())
+			Expect(cacheMap.ResolveDependencyMap(Required)).To(Succeed())
+
+			mpv := cacheMap.Map["mpv"]
+			var conditions []string
+			for _, s := range mpv.Scopes() {
+				conditions = append(conditions, s.Condition)
+			}
+
+			Expect(conditions).To(Equal([]string{"if OS.mac?", "if OS.linux?"}))
+			Expect(cacheMap.Map["ffmpeg"].Condition).To(Equal(""))
+		})
+
+		It("Should scope dependencies again when the packages with other conditions are removed", func() {
+			packages := []brewfile.Entry{{Type: "brew", Name: "mpv", Condition: "if OS.mac?"}, {Type: "brew", Name: "vlc"}}
+			Expect(cacheMap.FromPackages(packages)).To(Succeed())
+			Expect(cacheMap.ResolveDependencyMap(Required)).To(Succeed())
+			Expect(cacheMap.Map["ffmpeg"].Condition).To(Equal(""))
+
+			Expect(cacheMap.Remove("vlc", Required)).To(Succeed())
+			Expect(cacheMap.Map["ffmpeg"].Condition).To(Equal("if OS.mac?"))
+			Expect(cacheMap.Map).ToNot(HaveKey("lua"))
+		})
+
+		It("Should write dependencies in the Brewfile of the packages depending on them", func() {
+			packages := []brewfile.Entry{{Type: "brew", Name: "mpv", File: "Brewfile.media"}, {Type: "brew", Name: "vlc", File: "Brewfile.work"}}
+			Expect(cacheMap.FromPackages(packages)).To(Succeed())
+			Expect(cacheMap.ResolveDependencyMap(Required)).To(Succeed())
+
+			Expect(cacheMap.Map["lua"].File).To(Equal("Brewfile.work"))
+			Expect(cacheMap.Map["ffmpeg"].File).To(Equal(""))
+			Expect(cacheMap.Map["x264"].File).To(Equal(""))
+		})
+	})
+
+	Describe("Resolving the dependencies of brews with args", func() {
+		BeforeEach(func() {
+			Expect(db.AddTestBrewsFromInfo(
+				Info{FullName: "ffmpeg", Dependencies: []string{"lame", "x264", "libvpx", "yasm"}, RecommendedDependencies: []string{"x264"}, OptionalDependencies: []string{"libvpx"}, BuildDependencies: []string{"yasm"}},
+				Info{FullName: "lame"},
+				Info{FullName: "x264"},
+				Info{FullName: "libvpx"},
+				Info{FullName: "yasm"},
+			)).To(Succeed())
+		})
+
+		It("Should include optional dependencies enabled with with-* args and exclude recommended dependencies disabled with without-* args", func() {
+			packages := []brewfile.Entry{{Type: "brew", Name: "ffmpeg", Options: brewfile.Options{{Key: "args", Value: brewfile.StringArray([]string{"with-libvpx", "without-x264"})}}}}
+			Expect(cacheMap.FromPackages(packages)).To(Succeed())
+			Expect(cacheMap.ResolveDependencyMap(Recommended)).To(Succeed())
+
+			Expect(cacheMap.Map["lame"].RequiredBy).To(Equal([]string{"ffmpeg"}))
+			Expect(cacheMap.Map["libvpx"].OptionalFor).To(Equal([]string{"ffmpeg"}))
+			Expect(cacheMap.Map).ToNot(HaveKey("x264"))
+			Expect(cacheMap.Map).ToNot(HaveKey("yasm"))
+		})
+
+		It("Should use the level set for a brew instead of the given level", func() {
+			packages := []brewfile.Entry{{Type: "brew", Name: "ffmpeg", Level: "build"}}
+			Expect(cacheMap.FromPackages(packages)).To(Succeed())
+			Expect(cacheMap.ResolveDependencyMap(Required)).To(Succeed())
+
+			Expect(cacheMap.Map["x264"].RecommendedFor).To(Equal([]string{"ffmpeg"}))
+			Expect(cacheMap.Map["libvpx"].OptionalFor).To(Equal([]string{"ffmpeg"}))
+			Expect(cacheMap.Map["yasm"].BuildOf).To(Equal([]string{"ffmpeg"}))
+
+			Expect(cacheMap.Remove("ffmpeg", Required)).To(Succeed())
+			Expect(cacheMap.Map).To(BeEmpty())
+		})
+
+		It("Should only include the dependency types in a level set", func() {
+			level, ok := ParseLevel("required,build")
+			Expect(ok).To(BeTrue())
+			Expect(FormatLevel(level)).To(Equal("required,build"))
+
+			Expect(cacheMap.Add(Entry{Name: "ffmpeg"}, level)).To(Succeed())
+
+			Expect(cacheMap.Map["lame"].RequiredBy).To(Equal([]string{"ffmpeg"}))
+			Expect(cacheMap.Map["yasm"].BuildOf).To(Equal([]string{"ffmpeg"}))
+			Expect(cacheMap.Map).ToNot(HaveKey("x264"))
+			Expect(cacheMap.Map).ToNot(HaveKey("libvpx"))
+
+			Expect(cacheMap.Remove("ffmpeg", level)).To(Succeed())
+			Expect(cacheMap.Map).To(BeEmpty())
+		})
+
+		It("Should parse the level presets and reject unknown dependency types", func() {
+			level, ok := ParseLevel("optional")
+			Expect(ok).To(BeTrue())
+			Expect(level).To(Equal(Optional))
+
+			level, ok = ParseLevel("Required, Recommended")
+			Expect(ok).To(BeTrue())
+			Expect(level).To(Equal(Recommended))
+
+			_, ok = ParseLevel("required,test")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("Should include the build dependencies of brews built from HEAD at any level", func() {
+			Expect(cacheMap.Add(Entry{Name: "ffmpeg", Args: []string{"HEAD"}}, Required)).To(Succeed())
+
+			Expect(cacheMap.Map["yasm"].BuildOf).To(Equal([]string{"ffmpeg"}))
+			Expect(cacheMap.Map).ToNot(HaveKey("x264"))
+			Expect(cacheMap.Map).ToNot(HaveKey("libvpx"))
+		})
+
+		It("Should remove the dependencies enabled by args along with the brew", func() {
+			Expect(cacheMap.Add(Entry{Name: "ffmpeg", Args: []string{"with-libvpx", "build-from-source"}}, Required)).To(Succeed())
+			Expect(cacheMap.Map).To(HaveKey("libvpx"))
+			Expect(cacheMap.Map).To(HaveKey("yasm"))
+
+			Expect(cacheMap.Remove("ffmpeg", Required)).To(Succeed())
+			Expect(cacheMap.Map).To(BeEmpty())
+		})
+	})
+
+	Describe("Resolving casks", func() {
+		BeforeEach(func() {
+			var wine Info
+			Expect(json.Unmarshal([]byte(`{"full_name": "wine", "requirements": [{"name": "x11", "cask": "xquartz"}]}`), &wine)).To(Succeed())
+			Expect(db.AddTestBrewsFromInfo(wine, Info{FullName: "openjdk"})).To(Succeed())
+
+			var tool, java CaskInfo
+			tool.Token, tool.DependsOn.Formula, tool.DependsOn.Cask = "tool", []string{"openjdk"}, []string{"java"}
+			java.Token = "java"
+			Expect(db.AddTestCasksFromInfo(tool, java, CaskInfo{Token: "xquartz"})).To(Succeed())
+
+			cacheMap.Casks = make(Map)
+		})
+
+		It("Should map the casks met by the requirements of brews to be required by them", func() {
+			Expect(cacheMap.FromPackages([]brewfile.Entry{{Type: "brew", Name: "wine"}})).To(Succeed())
+			Expect(cacheMap.ResolveDependencyMap(Required)).To(Succeed())
+
+			Expect(cacheMap.Casks["xquartz"].RequiredBy).To(Equal([]string{"wine"}))
+
+			cask := cacheMap.Casks["xquartz"]
+			xquartz, err := cask.BrewfileEntry(nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(xquartz.String()).To(Equal("cask 'xquartz' # [required by: wine]"))
+
+			Expect(cacheMap.Remove("wine", Required)).To(Succeed())
+			Expect(cacheMap.Casks).To(BeEmpty())
+		})
+
+		It("Should map the brews and casks casks depend on to be required by them, keeping their options", func() {
+			file, err := brewfile.Parse("Brewfile", []byte(`cask 'tool', args: { appdir: '~/Applications' }`))
+			Expect(err).ToNot(HaveOccurred())
+
+			var packages brewfile.Packages
+			packages.FromFile(file)
+
+			Expect(cacheMap.FromCasks(packages.Cask)).To(Succeed())
+			Expect(cacheMap.ResolveDependencyMap(Required)).To(Succeed())
+
+			Expect(cacheMap.Map["openjdk"].RequiredBy).To(Equal([]string{"tool"}))
+			Expect(cacheMap.Casks["java"].RequiredBy).To(Equal([]string{"tool"}))
+
+			cask := cacheMap.Casks["tool"]
+			tool, err := cask.Format(nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tool).To(Equal("cask 'tool', args: { appdir: '~/Applications' }"))
+
+			Expect(cacheMap.RemoveCask("tool", Required)).To(Succeed())
+			Expect(cacheMap.Map).To(BeEmpty())
+			Expect(cacheMap.Casks).To(BeEmpty())
+		})
+
+		It("Should keep an explicit brew required by a cask when the cask is removed", func() {
+			Expect(cacheMap.FromPackages([]brewfile.Entry{{Type: "brew", Name: "openjdk", Explicit: true}})).To(Succeed())
+			Expect(cacheMap.FromCasks([]brewfile.Entry{{Type: "cask", Name: "tool"}})).To(Succeed())
+			Expect(cacheMap.ResolveDependencyMap(Required)).To(Succeed())
+			Expect(cacheMap.Map["openjdk"].RequiredBy).To(Equal([]string{"tool"}))
+
+			Expect(cacheMap.RemoveCask("tool", Required)).To(Succeed())
+			Expect(cacheMap.Map).To(HaveKey("openjdk"))
+			Expect(cacheMap.Map["openjdk"].RequiredBy).To(BeEmpty())
+		})
+	})
+
+	Describe("Finding the taps of packages", func() {
+		It("Should list the taps brews and casks come from, leaving out the taps Homebrew comes with", func() {
+			cacheMap.Map["crisidev/chunkwm/chunkwm"] = Entry{Name: "crisidev/chunkwm/chunkwm"}
+			cacheMap.Map["homebrew/core/jq"] = Entry{Name: "homebrew/core/jq"}
+			cacheMap.Map["vim"] = Entry{Name: "vim"}
+			cacheMap.Casks = Map{"homebrew/cask-fonts/font-hack": Entry{Name: "homebrew/cask-fonts/font-hack"}}
+
+			Expect(cacheMap.Taps()).To(Equal([]string{"crisidev/chunkwm", "homebrew/cask-fonts"}))
+
+			brews, casks := cacheMap.FromTap("crisidev/homebrew-chunkwm")
+			Expect(brews).To(Equal([]string{"crisidev/chunkwm/chunkwm"}))
+			Expect(casks).To(BeEmpty())
+
+			Expect(SameTap("Homebrew/homebrew-cask-fonts", "homebrew/cask-fonts")).To(BeTrue())
+			Expect(SameTap("crisidev/chunkwm", "koekeishiya/formulae")).To(BeFalse())
+		})
+	})
+
+	Describe("Detecting conflicts", func() {
+		BeforeEach(func() {
+			Expect(db.AddTestBrewsFromInfo(
+				Info{FullName: "wordpress", Dependencies: []string{"mariadb"}},
+				Info{FullName: "mariadb", ConflictsWith: []string{"mysql"}},
+				Info{FullName: "mysql", ConflictsWith: []string{"mariadb"}},
+			)).To(Succeed())
+		})
+
+		It("Should report each pair of conflicting brews once with the chains bringing them in", func() {
+			Expect(cacheMap.FromPackages([]brewfile.Entry{
+				{Type: "brew", Name: "mysql", Pos: brewfile.Pos{Line: 1, Column: 1}},
+				{Type: "brew", Name: "wordpress", Pos: brewfile.Pos{Line: 2, Column: 1}},
+			})).To(Succeed())
+			Expect(cacheMap.ResolveDependencyMap(Required)).To(Succeed())
+
+			conflicts := cacheMap.Conflicts()
+			Expect(conflicts).To(HaveLen(1))
+			Expect(conflicts[0].String()).To(Equal("mariadb conflicts with mysql.\n  mariadb comes in through wordpress -> mariadb (required).\n  mysql is a primary brew."))
+
+			Expect(cacheMap.CheckConflicts(conflicts)).To(Succeed())
+
+			err := cacheMap.CheckConflicts(nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("mariadb conflicts with mysql."))
+		})
+
+		It("Should leave out pairs acknowledged with the conflicts_with option", func() {
+			Expect(cacheMap.FromPackages([]brewfile.Entry{
+				{Type: "brew", Name: "mysql", Options: brewfile.Options{{Key: "conflicts_with", Value: brewfile.StringArray([]string{"mariadb"})}}},
+				{Type: "brew", Name: "wordpress"},
+			})).To(Succeed())
+			Expect(cacheMap.ResolveDependencyMap(Required)).To(Succeed())
+
+			Expect(cacheMap.Conflicts()).To(BeEmpty())
+		})
+	})
+
+	Describe("Resolving the dependencies of installed brews", func() {
+		installed := func(source string) Info {
+			var info Info
+			Expect(json.Unmarshal([]byte(source), &info)).To(Succeed())
+			return info
+		}
+
+		BeforeEach(func() {
+			Expect(db.AddTestBrewsFromInfo(
+				installed(`{"full_name": "neovim", "dependencies": ["gettext", "libuv", "luajit", "lua"], "recommended_dependencies": ["luajit"], "optional_dependencies": ["lua"],
+					"installed": [{"version": "0.2.0", "runtime_dependencies": [{"full_name": "gettext"}, {"full_name": "libintl"}, {"full_name": "libuv"}, {"full_name": "lua"}, {"full_name": "unibilium"}]}]}`),
+				installed(`{"full_name": "gettext", "installed": [{"version": "0.19", "runtime_dependencies": [{"full_name": "libintl"}]}]}`),
+				installed(`{"full_name": "libuv", "installed": [{"version": "1.0"}]}`),
+				Info{FullName: "libintl"},
+				Info{FullName: "lua"},
+				Info{FullName: "luajit"},
+				Info{FullName: "unibilium"},
+			)).To(Succeed())
+		})
+
+		It("Should use the runtime dependencies recorded for the keg without those pulled in by other dependencies", func() {
+			cacheMap.Installed = true
+			Expect(cacheMap.FromPackages([]brewfile.Entry{{Type: "brew", Name: "neovim"}})).To(Succeed())
+			Expect(cacheMap.ResolveDependencyMap(Optional)).To(Succeed())
+
+			neovim := cacheMap.Map["neovim"]
+			Expect(neovim.RequiredDependencies).To(Equal([]string{"gettext", "libuv", "unibilium"}))
+			Expect(neovim.RecommendedDependencies).To(BeEmpty())
+			Expect(neovim.OptionalDependencies).To(Equal([]string{"lua"}))
+
+			Expect(cacheMap.Map["unibilium"].RequiredBy).To(Equal([]string{"neovim"}))
+			Expect(cacheMap.Map["libintl"].RequiredBy).To(Equal([]string{"gettext"}))
+			Expect(cacheMap.Map["lua"].OptionalFor).To(Equal([]string{"neovim"}))
+			Expect(cacheMap.Map).ToNot(HaveKey("luajit"))
+		})
+
+		It("Should use the dependencies of the formulae when not resolving installed brews", func() {
+			Expect(cacheMap.FromPackages([]brewfile.Entry{{Type: "brew", Name: "neovim"}})).To(Succeed())
+			Expect(cacheMap.ResolveDependencyMap(Optional)).To(Succeed())
+
+			Expect(cacheMap.Map["neovim"].RequiredDependencies).To(Equal([]string{"gettext", "libuv"}))
+			Expect(cacheMap.Map["luajit"].RecommendedFor).To(Equal([]string{"neovim"}))
+			Expect(cacheMap.Map).ToNot(HaveKey("unibilium"))
+		})
 	})
 
 	Describe("With a functioning bolt db", func() {
@@ -3,11 +3,14 @@ package brew_test
 import (
 	. "github.com/LGUG2Z/bfm/brew"
 
+	"encoding/json"
 	"fmt"
 	"os"
 
 	"os/exec"
+	"time"
 
+	"github.com/boltdb/bolt"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -78,6 +81,26 @@ var _ = Describe("Cache", func() {
 			Expect(actual).To(Equal(expected))
 		})
 
+		It("Should find the Info of a brew by one of its aliases or its old name", func() {
+			command := exec.Command("echo", `[ { "full_name": "python@3.9", "aliases": ["python3"], "oldname": "python" } ]`)
+			Expect(cache.Refresh(command)).To(Succeed())
+
+			for _, name := range []string{"python@3.9", "python3", "python"} {
+				actual, err := cache.Find(name)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(actual.FullName).To(Equal("python@3.9"))
+			}
+		})
+
+		It("Should forget aliases which have been dropped when refreshed", func() {
+			Expect(cache.Refresh(exec.Command("echo", `[ { "full_name": "python@3.9", "aliases": ["python3"] } ]`))).To(Succeed())
+			Expect(cache.Refresh(exec.Command("echo", `[ { "full_name": "python@3.9" }, { "full_name": "python@3.10", "aliases": ["python3"] } ]`))).To(Succeed())
+
+			actual, err := cache.Find("python3")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(actual.FullName).To(Equal("python@3.10"))
+		})
+
 		It("Should return an error if a brew cannot be found", func() {
 			Expect(db.AddTestBrews("vim")).To(Succeed())
 
@@ -86,5 +109,212 @@ var _ = Describe("Cache", func() {
 			Expect(err).ToNot(BeNil())
 			Expect(err.Error()).To(Equal(ErrCouldNotFindPackageInfo("notvim").Error()))
 		})
+
+		It("Should find the info of a cask by its full token or by its bare token", func() {
+			fonts := CaskInfo{Token: "font-hack", FullToken: "homebrew/cask-fonts/font-hack"}
+			fonts.DependsOn.Formula = []string{"jq"}
+			other := CaskInfo{Token: "font-hack", FullToken: "other/tap/font-hack"}
+			Expect(cache.PutCaskInfo([]CaskInfo{other, fonts, {Token: "firefox", FullToken: "firefox"}})).To(Succeed())
+
+			actual, err := cache.FindCaskInfo("homebrew/cask-fonts/font-hack")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(actual.DependsOn.Formula).To(Equal([]string{"jq"}))
+
+			actual, err = cache.FindCaskInfo("other/tap/font-hack")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(actual.FullToken).To(Equal("other/tap/font-hack"))
+
+			actual, err = cache.FindCaskInfo("font-hack")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(actual.FullToken).To(Equal("homebrew/cask-fonts/font-hack"))
+
+			actual, err = cache.FindCaskInfo("firefox")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(actual.Token).To(Equal("firefox"))
+		})
+
+		It("Should return an error if a cask is looked up before any cask has been cached", func() {
+			_, err := cache.FindCask("firefox")
+
+			Expect(err).ToNot(BeNil())
+			Expect(err.Error()).To(Equal(ErrCouldNotFindPackageInfo("firefox").Error()))
+		})
+	})
+
+	Describe("Recording how the Cache was built", func() {
+		It("Should store the Homebrew version, the installed taps and the time of the refresh", func() {
+			version := exec.Command("echo", "Homebrew 4.1.0\nHomebrew/homebrew-core (git revision abc; last commit 2023-07-01)")
+			taps := exec.Command("echo", `[ { "name": "homebrew/core", "HEAD": "abc" }, { "name": "crisidev/chunkwm", "HEAD": "def" } ]`)
+
+			before := time.Now().Add(-time.Second)
+			Expect(cache.RefreshMeta(version, taps)).To(Succeed())
+
+			meta, err := cache.Meta()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(meta.SchemaVersion).To(Equal(SchemaVersion))
+			Expect(meta.HomebrewVersion).To(Equal("4.1.0"))
+			Expect(meta.Taps).To(Equal([]TapInfo{{Name: "homebrew/core", Commit: "abc"}, {Name: "crisidev/chunkwm", Commit: "def"}}))
+			Expect(meta.RefreshedAt).To(BeTemporally(">", before))
+		})
+	})
+
+	Describe("Migrating a Cache built by an older version of bfm", func() {
+		It("Should leave a current Cache alone", func() {
+			Expect(db.AddTestBrews("vim")).To(Succeed())
+			Expect(cache.RefreshMeta(exec.Command("echo", "Homebrew 4.1.0"), exec.Command("echo", "[]"))).To(Succeed())
+
+			rebuild, err := cache.Migrate()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rebuild).To(BeFalse())
+
+			meta, err := cache.Meta()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(meta.HomebrewVersion).To(Equal("4.1.0"))
+		})
+
+		It("Should empty a Cache which cannot be upgraded in place to be rebuilt", func() {
+			Expect(db.AddTestBrewsFromInfo(Info{FullName: "python@3.9", Aliases: []string{"python3"}})).To(Succeed())
+
+			meta, err := cache.Meta()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(meta.SchemaVersion).To(Equal(1))
+
+			rebuild, err := cache.Migrate()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rebuild).To(BeTrue())
+
+			_, err = cache.Find("python@3.9")
+			Expect(err).To(HaveOccurred())
+
+			meta, err = cache.Meta()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(meta.SchemaVersion).To(Equal(SchemaVersion))
+		})
+
+		It("Should upgrade a Cache with cask info in place", func() {
+			Expect(db.AddTestBrewsFromInfo(Info{FullName: "python@3.9", Aliases: []string{"python3"}})).To(Succeed())
+			Expect(db.Update(func(tx *bolt.Tx) error {
+				for _, name := range []string{"alias", "cask_info"} {
+					if _, err := tx.CreateBucket([]byte(name)); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			})).To(Succeed())
+
+			rebuild, err := cache.Migrate()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rebuild).To(BeFalse())
+
+			actual, err := cache.Find("python@3.9")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(actual.Aliases).To(Equal([]string{"python3"}))
+
+			meta, err := cache.Meta()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(meta.SchemaVersion).To(Equal(SchemaVersion))
+		})
+
+		It("Should store the info of casks under their full tokens", func() {
+			fonts, err := json.Marshal(CaskInfo{Token: "font-hack", FullToken: "homebrew/cask-fonts/font-hack"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(db.Update(func(tx *bolt.Tx) error {
+				meta, err := tx.CreateBucket([]byte("meta"))
+				if err != nil {
+					return err
+				}
+
+				if err := meta.Put([]byte("schema_version"), []byte("4")); err != nil {
+					return err
+				}
+
+				b, err := tx.CreateBucket([]byte("cask_info"))
+				if err != nil {
+					return err
+				}
+
+				return b.Put([]byte("font-hack"), fonts)
+			})).To(Succeed())
+
+			rebuild, err := cache.Migrate()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rebuild).To(BeFalse())
+
+			for _, token := range []string{"homebrew/cask-fonts/font-hack", "font-hack"} {
+				actual, err := cache.FindCaskInfo(token)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(actual.FullToken).To(Equal("homebrew/cask-fonts/font-hack"))
+			}
+
+			Expect(db.View(func(tx *bolt.Tx) error {
+				Expect(tx.Bucket([]byte("cask_info")).Get([]byte("font-hack"))).To(BeNil())
+				return nil
+			})).To(Succeed())
+		})
+
+		It("Should refuse a Cache built by a newer version of bfm", func() {
+			Expect(db.Update(func(tx *bolt.Tx) error {
+				b, err := tx.CreateBucket([]byte("meta"))
+				if err != nil {
+					return err
+				}
+
+				return b.Put([]byte("schema_version"), []byte(fmt.Sprint(SchemaVersion+1)))
+			})).To(Succeed())
+
+			_, err := cache.Migrate()
+			Expect(err).To(Equal(ErrNewerCache(SchemaVersion + 1)))
+		})
+	})
+
+	Describe("Detecting a stale Cache", func() {
+		var (
+			refreshedAt = time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+			meta        = Meta{RefreshedAt: refreshedAt, Taps: []TapInfo{{Name: "homebrew/core", Commit: "abc"}, {Name: "homebrew/services", Commit: "def"}}}
+		)
+
+		It("Should not be stale within the ttl with the same taps at the same commits", func() {
+			Expect(meta.Staleness(time.Hour, meta.Taps, refreshedAt.Add(time.Minute))).To(BeEmpty())
+		})
+
+		It("Should be stale after the ttl unless the ttl is zero", func() {
+			Expect(meta.Staleness(time.Hour, meta.Taps, refreshedAt.Add(3*time.Hour))).To(Equal([]string{"it was last refreshed 3h0m0s ago"}))
+			Expect(meta.Staleness(0, meta.Taps, refreshedAt.Add(3*time.Hour))).To(BeEmpty())
+		})
+
+		It("Should be stale after taps have been tapped, untapped or updated", func() {
+			taps := []TapInfo{{Name: "homebrew/core", Commit: "bcd"}, {Name: "crisidev/chunkwm", Commit: "efa"}}
+
+			Expect(meta.Staleness(0, taps, refreshedAt)).To(Equal([]string{
+				"homebrew/core has been updated",
+				"crisidev/chunkwm has been tapped",
+				"homebrew/services has been untapped",
+			}))
+		})
+
+		It("Should be stale if it was never refreshed with its Meta recorded", func() {
+			Expect(Meta{}.Staleness(0, nil, refreshedAt)).To(Equal([]string{"it has not been refreshed by this version of bfm"}))
+		})
+
+		It("Should try a lookup which misses once more after the Cache was refreshed", func() {
+			Expect(db.AddTestBrews("vim")).To(Succeed())
+
+			var missed []string
+			cache.OnMiss = func(c *Cache, name string) (bool, error) {
+				missed = append(missed, name)
+				return true, c.Refresh(exec.Command("echo", `[ { "full_name": "crisidev/chunkwm/chunkwm" } ]`))
+			}
+			defer func() { cache.OnMiss = nil }()
+
+			actual, err := cache.Find("crisidev/chunkwm/chunkwm")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(actual.FullName).To(Equal("crisidev/chunkwm/chunkwm"))
+
+			_, err = cache.Find("vim")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missed).To(Equal([]string{"crisidev/chunkwm/chunkwm"}))
+		})
 	})
 })
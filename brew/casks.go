@@ -0,0 +1,169 @@
+package brew
+
+import (
+	"github.com/LGUG2Z/bfm/brewfile"
+	"github.com/LGUG2Z/bfm/diagnostics"
+	"github.com/LGUG2Z/bfm/graph"
+	. "github.com/LGUG2Z/bfm/helpers"
+)
+
+// Creates an entry in the cask map for every cask in a Brewfile, filled with the
+// info of the cask from the cache. Casks without info in the cache are kept
+// without dependencies. Dependencies are not resolved at this stage.
+func (c CacheMap) FromCasks(casks []brewfile.Entry) error {
+	var errs diagnostics.List
+
+	for _, p := range casks {
+		e := c.caskEntry(p.Name)
+		if err := e.FromBrewfileEntry(p); err != nil {
+			errs.Add(p.Pos, err)
+			continue
+		}
+
+		c.Casks.put(e)
+	}
+
+	return errs.Err()
+}
+
+// Add a cask to the cask map and resolve the brews and casks it requires.
+func (c CacheMap) AddCask(cask brewfile.Entry, level int) error {
+	if err := c.FromCasks([]brewfile.Entry{cask}); err != nil {
+		return err
+	}
+
+	return c.ResolveDependencyMap(level)
+}
+
+// Remove a cask from the cask map along with the brews and casks only it requires.
+func (c CacheMap) RemoveCask(name string, level int) error {
+	cask, present := c.Casks[name]
+	if !present {
+		return nil
+	}
+
+	delete(c.Casks, name)
+
+	for _, dep := range cask.RequiredDependencies {
+		c.removeDependency(dep, name, RequiredDependency)
+
+		if d, present := c.Map[dep]; present && len(d.RequiredBy) < 1 && !d.Explicit {
+			if err := c.Remove(d.Name, level); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, dep := range cask.CaskDependencies {
+		if err := c.removeCaskDependency(dep, name, level); err != nil {
+			return err
+		}
+	}
+
+	c.resolveScopes()
+	return nil
+}
+
+// Returns the entry of a cask in the cask map, or a new entry filled with the
+// info of the cask if it is not in the cask map yet. The entry keeps the name
+// given, whether it is the full token of the cask or its bare token.
+func (c CacheMap) caskEntry(name string) Entry {
+	if e, present := c.Casks[name]; present {
+		return e
+	}
+
+	e := Entry{Type: "cask", Name: name}
+	if info, err := c.Cache.FindCaskInfo(name); err == nil {
+		e.FromCaskInfo(info)
+		e.Name = name
+	}
+
+	return e
+}
+
+// Returns a dependency graph of the casks in the cache, in which the edges
+// leaving a cask are the casks it depends on.
+func (c CacheMap) caskGraph() *graph.Graph {
+	return graph.New(func(name string, kind graph.Kind) ([]string, error) {
+		e := c.caskEntry(name)
+		return e.CaskDependencies, nil
+	})
+}
+
+// Resolves the casks required by the brews and casks in the CacheMap, through the
+// requirements of formulae and the casks each cask depends on, and the brews
+// required by those casks, until every package reached has been resolved.
+func (c CacheMap) resolveCasks(g *graph.Graph) error {
+	if c.Casks == nil {
+		return nil
+	}
+
+	var errs diagnostics.List
+	casks := c.caskGraph()
+	brews, resolved := make(map[string]bool), make(map[string]bool)
+
+	for progress := true; progress; {
+		progress = false
+
+		for _, name := range c.names() {
+			if brews[name] {
+				continue
+			}
+
+			brews[name], progress = true, true
+			b := c.Map[name]
+
+			for _, d := range b.CaskDependencies {
+				errs.Add(b.Pos, c.addCaskDependency(casks, d, name))
+			}
+		}
+
+		for _, name := range c.Casks.names() {
+			if resolved[name] {
+				continue
+			}
+
+			resolved[name], progress = true, true
+			k := c.Casks[name]
+
+			for _, d := range k.RequiredDependencies {
+				errs.Add(k.Pos, c.addDependency(g, d, name, RequiredDependency))
+			}
+
+			for _, d := range k.CaskDependencies {
+				errs.Add(k.Pos, c.addCaskDependency(casks, d, name))
+			}
+		}
+	}
+
+	return errs.Err()
+}
+
+// Map a cask to be required by a brew or another cask, along with the casks it
+// depends on.
+func (c CacheMap) addCaskDependency(casks *graph.Graph, req, by string) error {
+	return casks.Walk(by, req, graph.Required, func(by, req string) error {
+		e := c.caskEntry(req)
+		e.addDependent(by, RequiredDependency)
+		c.Casks[e.Name] = e
+		return nil
+	})
+}
+
+// Unmap a cask as required by a package upon removal of that package, removing
+// the cask too if nothing else requires it.
+func (c CacheMap) removeCaskDependency(req, by string, level int) error {
+	k, present := c.Casks[req]
+	if !present {
+		return nil
+	}
+
+	k.RequiredBy = Remove(k.RequiredBy, by)
+	c.Casks[req] = k
+
+	if len(k.RequiredBy) < 1 && !k.Explicit {
+		return c.RemoveCask(req, level)
+	}
+
+	return nil
+}
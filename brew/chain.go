@@ -0,0 +1,73 @@
+package brew
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/LGUG2Z/bfm/graph"
+)
+
+// Link is a package in a dependency chain, with the type of dependency it is of
+// the package before it in the chain.
+type Link struct {
+	Name           string
+	DependencyType int
+}
+
+// Chain is a chain of dependencies leading from a primary brew, which no other
+// brew depends on, down to one of its dependencies.
+type Chain []Link
+
+func (c Chain) String() string {
+	var links []string
+	for i, l := range c {
+		if i == 0 {
+			links = append(links, l.Name)
+			continue
+		}
+
+		links = append(links, fmt.Sprintf("%s (%s)", l.Name, graph.Kind(l.DependencyType)))
+	}
+
+	return strings.Join(links, " -> ")
+}
+
+// Returns every chain of the dependencies resolved by ResolveDependencyMap which
+// leads from a primary brew down to the given package, sorted by the packages
+// along them. A package which no other package depends on has no chains.
+func (c CacheMap) Chains(name string) []Chain {
+	var chains []Chain
+	visiting := map[string]bool{name: true}
+
+	var walk func(name string, below Chain)
+	walk = func(name string, below Chain) {
+		e, _ := c.Lookup(name)
+		primary := true
+
+		for _, dependencyType := range dependencyTypes {
+			for _, d := range *e.dependents(dependencyType) {
+				primary = false
+				if visiting[d] {
+					continue
+				}
+
+				visiting[d] = true
+				walk(d, append(Chain{{Name: name, DependencyType: dependencyType}}, below...))
+				visiting[d] = false
+			}
+		}
+
+		if primary && len(below) > 0 {
+			chains = append(chains, append(Chain{{Name: name}}, below...))
+		}
+	}
+
+	walk(name, nil)
+
+	sort.Slice(chains, func(i, j int) bool {
+		return chains[i].String() < chains[j].String()
+	})
+
+	return chains
+}
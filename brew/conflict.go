@@ -0,0 +1,85 @@
+package brew
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/LGUG2Z/bfm/diagnostics"
+	. "github.com/LGUG2Z/bfm/helpers"
+)
+
+// Conflict is a pair of brews in the resolved dependencies whose formulae conflict
+// with each other, with the chains of dependencies bringing each of them in.
+type Conflict struct {
+	Name, With         string
+	Chains, WithChains []Chain
+}
+
+func (c Conflict) String() string {
+	lines := []string{fmt.Sprintf("%s conflicts with %s.", c.Name, c.With)}
+
+	for _, side := range []struct {
+		name   string
+		chains []Chain
+	}{{c.Name, c.Chains}, {c.With, c.WithChains}} {
+		if len(side.chains) < 1 {
+			lines = append(lines, fmt.Sprintf("  %s is a primary brew.", side.name))
+			continue
+		}
+
+		for _, chain := range side.chains {
+			lines = append(lines, fmt.Sprintf("  %s comes in through %s.", side.name, chain))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Returns every pair of brews in the resolved dependencies whose formulae
+// conflict, sorted by name. Pairs in which one of the brews lists the other in
+// its conflicts_with option are left out, as Homebrew Bundle unlinks the other
+// brew before installing it.
+func (c CacheMap) Conflicts() []Conflict {
+	var conflicts []Conflict
+
+	for _, name := range c.names() {
+		e := c.Map[name]
+
+		for _, with := range e.FormulaConflicts {
+			other, present := c.Map[with]
+			if !present || Contains(e.ConflictsWith, with) || Contains(other.ConflictsWith, name) {
+				continue
+			}
+
+			if Contains(other.FormulaConflicts, name) && with < name {
+				continue
+			}
+
+			conflicts = append(conflicts, Conflict{Name: name, With: with, Chains: c.Chains(name), WithChains: c.Chains(with)})
+		}
+	}
+
+	return conflicts
+}
+
+// Reports every conflict in the resolved dependencies which is not one of the
+// known conflicts given, at the position of the first brew of the pair in the
+// Brewfile.
+func (c CacheMap) CheckConflicts(known []Conflict) error {
+	var errs diagnostics.List
+
+	for _, conflict := range c.Conflicts() {
+		isKnown := false
+		for _, k := range known {
+			if k.Name == conflict.Name && k.With == conflict.With {
+				isKnown = true
+			}
+		}
+
+		if !isKnown {
+			errs.Add(c.Map[conflict.Name].Pos, ErrConflict(conflict))
+		}
+	}
+
+	return errs.Err()
+}
@@ -1,25 +1,80 @@
 package brew
 
-import "fmt"
+import (
+	"fmt"
 
+	"github.com/LGUG2Z/bfm/brewfile"
+	"github.com/LGUG2Z/bfm/diagnostics"
+	"github.com/LGUG2Z/bfm/graph"
+)
+
+// Dependency types, which are the kinds of the edges of the dependency graph. A
+// dependency level is a set of dependency types.
 const (
-	RequiredDependency = iota
-	RecommendedDependency
-	OptionalDependency
-	BuildDependency
+	RequiredDependency    = int(graph.Required)
+	RecommendedDependency = int(graph.Recommended)
+	OptionalDependency    = int(graph.Optional)
+	BuildDependency       = int(graph.Build)
 )
 
+// The dependency types in the order they are resolved.
+var dependencyTypes = []int{RequiredDependency, RecommendedDependency, OptionalDependency, BuildDependency}
+
+// Dependency levels, as the sets of dependency types resolved at each level.
+// Required dependencies are resolved at every level.
 const (
-	Required = iota
-	Recommended
-	Optional
-	Build
+	Required    = 0
+	Recommended = Required | RecommendedDependency
+	Optional    = Recommended | OptionalDependency
+	Build       = Optional | BuildDependency
 )
 
 var (
+	ErrNewerCache = func(version int) error {
+		return &diagnostics.Diagnostic{
+			Message: fmt.Sprintf("The cache was built by a newer version of bfm, with schema version %d.", version),
+			Hint:    "Upgrade bfm, or delete '$HOME/.bfm.bolt' to rebuild the cache with this version.",
+		}
+	}
 	ErrCouldNotFindPackageInfo = func(name string) error {
-		return fmt.Errorf("Could not find information for %s. Aborting.\n"+
-			"If this package is from a new tap, run 'bfm refresh' to use info from the new tap.\n"+
-			"With manually added taps the full name format should be used: 'github_user/repo/package'.\n", name)
+		return &diagnostics.Diagnostic{
+			Message: fmt.Sprintf("Could not find information for %s. Aborting.", name),
+			Hint: "If this package is from a new tap, run 'bfm refresh' to use info from the new tap.\n" +
+				"With manually added taps the full name format should be used: 'github_user/repo/package'.",
+		}
+	}
+
+	ErrInvalidOptionValue = func(o brewfile.Option) error {
+		return diagnostics.Errorf(o.Value.Pos(), "invalid value %s for the %s option.", o.Value.String(), o.Key)
+	}
+
+	ErrConflict = func(c Conflict) error {
+		return &diagnostics.Diagnostic{
+			Message: c.String(),
+			Hint:    "Remove one of the conflicting brews, or list the other in the conflicts_with option of one of them.",
+		}
+	}
+
+	ErrStaleAnnotation = func(name, expected, found string) error {
+		return annotationDiagnostic("The annotation of %s is stale: expected %s, found %s.", name, expected, found)
+	}
+
+	ErrHandEditedAnnotation = func(name, expected, found string) error {
+		return annotationDiagnostic("The annotation of %s has been edited by hand: expected %s, found %s.", name, expected, found)
+	}
+
+	ErrMissingAnnotation = func(name, expected string) error {
+		return annotationDiagnostic("%s is a dependency of other brews but is not annotated: expected %s.", name, expected)
+	}
+
+	ErrAnnotatedPrimary = func(name, found string) error {
+		return annotationDiagnostic("%s is annotated with %s but no brew in the Brewfile depends on it.", name, found)
 	}
 )
+
+func annotationDiagnostic(format string, args ...interface{}) error {
+	return &diagnostics.Diagnostic{
+		Message: fmt.Sprintf(format, args...),
+		Hint:    "Run 'bfm clean' to regenerate the annotations.",
+	}
+}
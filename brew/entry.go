@@ -1,10 +1,10 @@
 package brew
 
 import (
-	"bytes"
+	"sort"
 	"strings"
-	"text/template"
 
+	"github.com/LGUG2Z/bfm/brewfile"
 	. "github.com/LGUG2Z/bfm/helpers"
 )
 
@@ -12,18 +12,36 @@ type Entry struct {
 	Args                    []string
 	BuildDependencies       []string
 	BuildOf                 []string
+	CaskDependencies        []string
+	Comment                 string
+	Condition               string
+	Copies                  []Entry
+	ConflictsWith           []string
+	Doc                     []string
+	Explicit                bool
+	File                    string
+	FormulaConflicts        []string
+	Interpolated            []string
+	Level                   string
+	Link                    string
 	Name                    string
 	OptionalDependencies    []string
 	OptionalFor             []string
+	Options                 brewfile.Options
+	Pos                     brewfile.Pos
+	Postinstall             string
 	RecommendedDependencies []string
 	RecommendedFor          []string
 	RequiredBy              []string
 	RequiredDependencies    []string
 	RestartService          string
+	StartService            string
+	Type                    string
 }
 
 func (e *Entry) FromInfo(i Info) {
 	e.Name = i.FullName
+	e.FormulaConflicts = i.ConflictsWith
 	e.DetermineDependencies(i)
 }
 
@@ -47,33 +65,336 @@ func (e *Entry) DetermineDependencies(i Info) {
 		e.RequiredDependencies = Remove(e.RequiredDependencies, recommended)
 		e.RecommendedDependencies = append(e.RecommendedDependencies, recommended)
 	}
+
+	e.CaskDependencies = i.CaskRequirements()
+}
+
+// Fills a cask Entry with the info of the cask, which requires the formulae and
+// casks it depends on.
+func (e *Entry) FromCaskInfo(i CaskInfo) {
+	e.Type = "cask"
+	e.Name = i.Token
+	e.RequiredDependencies = i.DependsOn.Formula
+	e.CaskDependencies = i.DependsOn.Cask
+}
+
+// Separates the runtime dependencies of an installed package into required,
+// recommended and optional, keeping the build dependencies of its formula.
+// Recommended and optional dependencies which were not installed are left out,
+// and dependencies installed through non-default options are required.
+func (e *Entry) DetermineInstalledDependencies(i Info, runtime []string) {
+	e.RequiredDependencies, e.RecommendedDependencies, e.OptionalDependencies = nil, nil, nil
+
+	for _, dependency := range runtime {
+		switch {
+		case Contains(i.RecommendedDependencies, dependency):
+			e.RecommendedDependencies = append(e.RecommendedDependencies, dependency)
+		case Contains(i.OptionalDependencies, dependency):
+			e.OptionalDependencies = append(e.OptionalDependencies, dependency)
+		default:
+			e.RequiredDependencies = append(e.RequiredDependencies, dependency)
+		}
+	}
+}
+
+// Returns the dependencies of the given type which are installed along with an
+// Entry at the given level, or at the level set for the Entry itself, taking its
+// args into account. Optional dependencies
+// enabled with a with-* arg, and the build dependencies of a brew built with HEAD
+// or build-from-source, are included at any level, while recommended dependencies
+// disabled with a without-* arg are left out.
+func (e *Entry) Dependencies(dependencyType, level int) []string {
+	var dependencies []string
+	level = e.level(level)
+
+	switch dependencyType {
+	case RequiredDependency:
+		dependencies = e.RequiredDependencies
+	case RecommendedDependency:
+		if level&RecommendedDependency != 0 {
+			for _, d := range e.RecommendedDependencies {
+				if !e.hasArg("without-" + optionName(d)) {
+					dependencies = append(dependencies, d)
+				}
+			}
+		}
+	case OptionalDependency:
+		for _, d := range e.OptionalDependencies {
+			if level&OptionalDependency != 0 || e.hasArg("with-"+optionName(d)) {
+				dependencies = append(dependencies, d)
+			}
+		}
+	case BuildDependency:
+		if level&BuildDependency != 0 || e.hasArg("HEAD") || e.hasArg("build-from-source") {
+			dependencies = e.BuildDependencies
+		}
+	}
+
+	return dependencies
+}
+
+// Returns the dependencies of the given type listed in the formula of an Entry.
+func (e *Entry) formulaDependencies(dependencyType int) []string {
+	switch dependencyType {
+	case RequiredDependency:
+		return e.RequiredDependencies
+	case RecommendedDependency:
+		return e.RecommendedDependencies
+	case OptionalDependency:
+		return e.OptionalDependencies
+	case BuildDependency:
+		return e.BuildDependencies
+	}
+
+	return nil
+}
+
+// Returns the packages depending on an Entry with the given type of dependency.
+func (e *Entry) dependents(dependencyType int) *[]string {
+	switch dependencyType {
+	case RequiredDependency:
+		return &e.RequiredBy
+	case RecommendedDependency:
+		return &e.RecommendedFor
+	case OptionalDependency:
+		return &e.OptionalFor
+	case BuildDependency:
+		return &e.BuildOf
+	}
+
+	return nil
+}
+
+// Records a package as depending on an Entry with the given type of dependency.
+func (e *Entry) addDependent(by string, dependencyType int) {
+	dependents := e.dependents(dependencyType)
+	if dependents == nil || Contains(*dependents, by) {
+		return
+	}
+
+	*dependents = append(*dependents, by)
+	sort.Strings(*dependents)
+}
+
+// Reports whether two entries of a package are in the same block of the same
+// Brewfile.
+func (e *Entry) sameScope(other Entry) bool {
+	return e.Condition == other.Condition && e.File == other.File
+}
+
+// Returns an Entry followed by its copies in other blocks and Brewfiles, each
+// with the packages depending on the Entry.
+func (e *Entry) Scopes() []Entry {
+	scopes := []Entry{*e}
+	for _, c := range e.Copies {
+		c.RequiredBy, c.RecommendedFor, c.OptionalFor, c.BuildOf = e.RequiredBy, e.RecommendedFor, e.OptionalFor, e.BuildOf
+		scopes = append(scopes, c)
+	}
+
+	return scopes
+}
+
+// Reports whether an Entry has the given arg, with or without leading dashes.
+func (e *Entry) hasArg(arg string) bool {
+	for _, a := range e.Args {
+		if strings.EqualFold(strings.TrimLeft(a, "-"), arg) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the name a dependency is referred to by in the with-* and without-*
+// options of a formula, which leaves out the tap of the dependency.
+func optionName(dependency string) string {
+	return dependency[strings.LastIndex(dependency, "/")+1:]
+}
+
+// Reads the options Homebrew Bundle supports for brews from a Brewfile entry,
+// carrying any other options and the comments of the entry through unchanged.
+// The options of casks are all carried through unchanged.
+func (e *Entry) FromBrewfileEntry(b brewfile.Entry) error {
+	e.Options, e.Interpolated = nil, nil
+
+	for _, o := range b.Options {
+		if b.Type == "cask" {
+			e.Options = append(e.Options, o)
+			continue
+		}
+
+		switch o.Key {
+		case "args", "conflicts_with", "postinstall":
+			e.Interpolated = append(e.Interpolated, brewfile.InterpolatedValues(o.Value)...)
+		}
+
+		switch o.Key {
+		case "args":
+			args, err := brewfile.StringValues(o.Value)
+			if err != nil {
+				return err
+			}
+			e.Args = args
+		case "conflicts_with":
+			conflicts, err := brewfile.StringValues(o.Value)
+			if err != nil {
+				return err
+			}
+			e.ConflictsWith = conflicts
+		case "link":
+			if !IsValidLink(o.Value.String()) {
+				return ErrInvalidOptionValue(o)
+			}
+			e.Link = o.Value.String()
+		case "postinstall":
+			command, ok := o.Value.(*brewfile.StringLit)
+			if !ok {
+				return ErrInvalidOptionValue(o)
+			}
+			e.Postinstall = command.Value
+		case "restart_service":
+			if !IsValidRestartService(o.Value.String()) {
+				return ErrInvalidOptionValue(o)
+			}
+			e.RestartService = o.Value.String()
+		case "start_service":
+			if !IsValidStartService(o.Value.String()) {
+				return ErrInvalidOptionValue(o)
+			}
+			e.StartService = o.Value.String()
+		default:
+			e.Options = append(e.Options, o)
+		}
+	}
+
+	e.Comment = b.Comment
+	e.Condition = b.Condition
+	e.Doc = b.Doc
+	e.Explicit = b.Explicit
+	e.File = b.File
+	e.Level = b.Level
+	e.Pos = b.Pos
+
+	return nil
 }
 
-// Format an brew Entry to be a valid Brewfile line.
-func (e *Entry) Format() (string, error) {
-	var bytes bytes.Buffer
+// Converts a brew or cask Entry to a Brewfile entry annotated with its dependents in the
+// style of the given layout, or of the default layout if nil. Options are written
+// in the order args, link, conflicts_with, restart_service, start_service,
+// postinstall, followed by any options bfm does not know about.
+func (e *Entry) BrewfileEntry(layout *brewfile.Layout) (brewfile.Entry, error) {
+	annotation, err := e.Annotation(layout)
+	if err != nil {
+		return brewfile.Entry{}, err
+	}
 
-	source := `brew '{{ .Name }}'
+	var options brewfile.Options
+	if len(e.Args) > 0 {
+		options = options.Set("args", e.stringArray(e.Args))
+	}
 
-	{{- if .Args -}} , args: ['{{ StringsJoin .Args "', '" }}'] {{- end -}}
+	if len(e.Link) > 0 {
+		options = options.Set("link", &brewfile.Expr{Source: e.Link})
+	}
 
-	{{- if .RestartService -}} , restart_service: {{ .RestartService }} {{- end -}}
+	if len(e.ConflictsWith) > 0 {
+		options = options.Set("conflicts_with", e.stringArray(e.ConflictsWith))
+	}
 
-	{{- if or .RequiredBy .RecommendedFor .OptionalFor .BuildOf }} # {{- end -}}
+	if len(e.RestartService) > 0 {
+		options = options.Set("restart_service", &brewfile.Expr{Source: e.RestartService})
+	}
 
-	{{- if .RequiredBy }} [required by: {{ StringsJoin .RequiredBy ", " }}] {{- end -}}
+	if len(e.StartService) > 0 {
+		options = options.Set("start_service", &brewfile.Expr{Source: e.StartService})
+	}
 
-	{{- if .RecommendedFor }} [recommended for: {{ StringsJoin .RecommendedFor ", " }}] {{- end -}}
+	if len(e.Postinstall) > 0 {
+		options = options.Set("postinstall", e.stringValue(e.Postinstall))
+	}
 
-	{{- if .OptionalFor }} [optional for: {{ StringsJoin .OptionalFor ", " }}] {{- end -}}
+	entryType := "brew"
+	if len(e.Type) > 0 {
+		entryType = e.Type
+	}
 
-	{{- if .BuildOf }} [build for: {{ StringsJoin .BuildOf ", " }}] {{- end -}}`
+	return brewfile.Entry{
+		Type:       entryType,
+		Name:       e.Name,
+		Options:    append(options, e.Options...),
+		Annotation: annotation,
+		Comment:    e.Comment,
+		Doc:        e.Doc,
+		Condition:  e.Condition,
+		File:       e.File,
+		Level:      e.Level,
+		Explicit:   e.Explicit,
+	}, nil
+}
+
+// Returns a string value, which keeps its interpolation if it was written with
+// interpolation in the Brewfile.
+func (e *Entry) stringValue(s string) *brewfile.StringLit {
+	return &brewfile.StringLit{Value: s, Interpolated: Contains(e.Interpolated, s)}
+}
 
-	funcMap := template.FuncMap{"StringsJoin": strings.Join}
-	tmpl := template.Must(template.New("brew").Funcs(funcMap).Parse(source))
-	if err := tmpl.Execute(&bytes, e); err != nil {
+// Returns a list of strings as a Ruby array value, keeping the interpolation of
+// the strings written with interpolation in the Brewfile.
+func (e *Entry) stringArray(values []string) brewfile.Value {
+	array := &brewfile.ArrayLit{}
+	for _, v := range values {
+		array.Elements = append(array.Elements, e.stringValue(v))
+	}
+
+	return array
+}
+
+// Reports whether a value can be given to the link option.
+func IsValidLink(value string) bool {
+	return value == "true" || value == "false" || value == ":overwrite"
+}
+
+// Reports whether a value can be given to the restart_service option.
+func IsValidRestartService(value string) bool {
+	return value == "true" || value == "false" || value == ":changed" || value == ":always"
+}
+
+// Reports whether a value can be given to the start_service option.
+func IsValidStartService(value string) bool {
+	return value == "true" || value == "false"
+}
+
+// Format an brew Entry to be a valid Brewfile line in the style of the given
+// layout, or of the default layout if nil.
+func (e *Entry) Format(layout *brewfile.Layout) (string, error) {
+	if layout == nil {
+		layout = brewfile.DefaultLayout()
+	}
+
+	b, err := e.BrewfileEntry(layout)
+	if err != nil {
 		return "", err
 	}
 
-	return bytes.String(), nil
+	return layout.Format(b), nil
+}
+
+// Creates the annotation listing the packages an Entry is a dependency of, using
+// the annotation template of the given layout, or of the default layout if nil.
+func (e *Entry) Annotation(layout *brewfile.Layout) (string, error) {
+	if layout == nil {
+		layout = brewfile.DefaultLayout()
+	}
+
+	return layout.Annotate(e.Dependents())
+}
+
+// Returns the packages an Entry is a dependency of.
+func (e *Entry) Dependents() brewfile.Dependents {
+	return brewfile.Dependents{
+		RequiredBy:     e.RequiredBy,
+		RecommendedFor: e.RecommendedFor,
+		OptionalFor:    e.OptionalFor,
+		BuildOf:        e.BuildOf,
+	}
 }
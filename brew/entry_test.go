@@ -3,6 +3,8 @@ package brew_test
 import (
 	. "github.com/LGUG2Z/bfm/brew"
 
+	"github.com/LGUG2Z/bfm/brewfile"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -60,7 +62,7 @@ var _ = Describe("Entry", func() {
 			expected := `brew 'vim'`
 			entry := Entry{Name: "vim"}
 
-			actual, err := entry.Format()
+			actual, err := entry.Format(nil)
 			Expect(err).To(BeNil())
 
 			Expect(actual).To(Equal(expected))
@@ -70,7 +72,25 @@ var _ = Describe("Entry", func() {
 			expected := `brew 'vim', args: ['HEAD'], restart_service: :changed`
 			entry := Entry{Name: "vim", RestartService: ":changed", Args: []string{"HEAD"}}
 
-			actual, err := entry.Format()
+			actual, err := entry.Format(nil)
+			Expect(err).To(BeNil())
+
+			Expect(actual).To(Equal(expected))
+		})
+
+		It("Formats the Entry with every option supported by Homebrew Bundle in a fixed order", func() {
+			expected := `brew 'mysql@5.7', args: ['with-debug'], link: :overwrite, conflicts_with: ['mysql', 'mariadb'], restart_service: :changed, start_service: true, postinstall: 'mysql.server start'`
+			entry := Entry{
+				Name:           "mysql@5.7",
+				Args:           []string{"with-debug"},
+				Postinstall:    "mysql.server start",
+				StartService:   "true",
+				RestartService: ":changed",
+				ConflictsWith:  []string{"mysql", "mariadb"},
+				Link:           ":overwrite",
+			}
+
+			actual, err := entry.Format(nil)
 			Expect(err).To(BeNil())
 
 			Expect(actual).To(Equal(expected))
@@ -80,10 +100,42 @@ var _ = Describe("Entry", func() {
 			expected := `brew 'vim' # [required by: developers]`
 			entry := Entry{Name: "vim", RequiredBy: []string{"developers"}}
 
-			actual, err := entry.Format()
+			actual, err := entry.Format(nil)
 			Expect(err).To(BeNil())
 
 			Expect(actual).To(Equal(expected))
 		})
 	})
+
+	Describe("With an entry read from a Brewfile", func() {
+		It("Reads every option supported by Homebrew Bundle", func() {
+			file, err := brewfile.Parse("Brewfile", []byte(`brew 'mysql@5.7', link: true, conflicts_with: ['mysql'], start_service: true, postinstall: "mysql.server start"`))
+			Expect(err).ToNot(HaveOccurred())
+
+			var packages brewfile.Packages
+			packages.FromFile(file)
+
+			var entry Entry
+			Expect(entry.FromBrewfileEntry(packages.Brew[0])).To(Succeed())
+
+			Expect(entry.Link).To(Equal("true"))
+			Expect(entry.ConflictsWith).To(Equal([]string{"mysql"}))
+			Expect(entry.StartService).To(Equal("true"))
+			Expect(entry.Postinstall).To(Equal("mysql.server start"))
+			Expect(entry.Options).To(BeEmpty())
+		})
+
+		It("Returns an error with the position of an invalid option value", func() {
+			file, err := brewfile.Parse("Brewfile", []byte(`brew 'mysql@5.7', link: :sometimes`))
+			Expect(err).ToNot(HaveOccurred())
+
+			var packages brewfile.Packages
+			packages.FromFile(file)
+
+			var entry Entry
+			err = entry.FromBrewfileEntry(packages.Brew[0])
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Brewfile:1:25: invalid value :sometimes for the link option."))
+		})
+	})
 })
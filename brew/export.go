@@ -0,0 +1,85 @@
+package brew
+
+import (
+	"strings"
+
+	"github.com/LGUG2Z/bfm/graph"
+)
+
+// Exports the dependencies resolved by ResolveDependencyMap as a graph, in which
+// brews and casks are told apart by the type of their nodes. With a root, only
+// the root and the packages it depends on are exported. With a positive depth,
+// only the packages at most that many dependencies away from the root, or from
+// the primary brews and casks without a root, are exported.
+func (c CacheMap) Export(root string, depth int) graph.Export {
+	dependencies := c.links(false)
+
+	var queue []string
+	if len(root) > 0 {
+		queue = append(queue, root)
+	} else {
+		for _, m := range []Map{c.Map, c.Casks} {
+			for name, e := range m {
+				if e.Dependents().IsEmpty() {
+					queue = append(queue, name)
+				}
+			}
+		}
+	}
+
+	distance := make(map[string]int)
+	for _, name := range queue {
+		distance[name] = 0
+	}
+
+	var export graph.Export
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		node := graph.Node{Name: name, Tap: tap(name), Type: "brew"}
+		e, _ := c.Lookup(name)
+		if e.Type == "cask" {
+			node.Tap, node.Type = caskTap(name), "cask"
+		}
+
+		node.Primary = e.Dependents().IsEmpty()
+		export.Nodes = append(export.Nodes, node)
+
+		if depth > 0 && distance[name] >= depth {
+			continue
+		}
+
+		for _, l := range dependencies[name] {
+			export.Edges = append(export.Edges, graph.Edge{From: name, To: l.Name, Kind: graph.Kind(l.DependencyType)})
+
+			if _, seen := distance[l.Name]; !seen {
+				distance[l.Name] = distance[name] + 1
+				queue = append(queue, l.Name)
+			}
+		}
+	}
+
+	export.Sort()
+	return export
+}
+
+// Returns the tap a package comes from, which is part of the full name of
+// packages from taps other than homebrew/core.
+func tap(name string) string {
+	if i := strings.LastIndex(name, "/"); i > 0 {
+		return name[:i]
+	}
+
+	return "homebrew/core"
+}
+
+// Returns the tap a cask comes from, which is part of the full name of casks
+// from taps other than homebrew/cask.
+func caskTap(name string) string {
+	if t := tap(name); t != "homebrew/core" {
+		return t
+	}
+
+	return "homebrew/cask"
+}
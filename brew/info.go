@@ -1,5 +1,7 @@
 package brew
 
+import . "github.com/LGUG2Z/bfm/helpers"
+
 type Info struct {
 	Name     string   `json:"name"`
 	FullName string   `json:"full_name"`
@@ -74,3 +76,72 @@ type Info struct {
 		} `json:"stable"`
 	} `json:"bottle"`
 }
+
+// Returns the full names of the runtime dependencies recorded for the most
+// recently installed keg of a package, reporting false if the package is not
+// installed or Homebrew did not record them.
+func (i Info) RuntimeDependencies() ([]string, bool) {
+	if len(i.Installed) < 1 {
+		return nil, false
+	}
+
+	keg := i.Installed[len(i.Installed)-1]
+	if keg.RuntimeDependencies == nil {
+		return nil, false
+	}
+
+	dependencies := []string{}
+	for _, d := range keg.RuntimeDependencies {
+		dependencies = append(dependencies, d.FullName)
+	}
+
+	return dependencies, true
+}
+
+// Returns the aliases and the old name of a formula, which it can be found by
+// besides its full name.
+func (i Info) OtherNames() []string {
+	names := append([]string{}, i.Aliases...)
+	if len(i.Oldname) > 0 {
+		names = append(names, i.Oldname)
+	}
+
+	return names
+}
+
+// Returns the casks the requirements of a formula are met by, such as xquartz
+// for formulae requiring X11.
+func (i Info) CaskRequirements() []string {
+	var casks []string
+	for _, r := range i.Requirements {
+		if len(r.Cask) > 0 && !Contains(casks, r.Cask) {
+			casks = append(casks, r.Cask)
+		}
+	}
+
+	return casks
+}
+
+type CaskInfo struct {
+	Token     string   `json:"token"`
+	FullToken string   `json:"full_token"`
+	Tap       string   `json:"tap"`
+	Name      []string `json:"name"`
+	Desc      string   `json:"desc"`
+	Homepage  string   `json:"homepage"`
+	Version   string   `json:"version"`
+	DependsOn struct {
+		Formula []string `json:"formula"`
+		Cask    []string `json:"cask"`
+	} `json:"depends_on"`
+}
+
+// Returns the full token of a cask, which includes the tap of casks from taps
+// other than homebrew/cask, or its token if Homebrew gave no full token.
+func (i CaskInfo) fullToken() string {
+	if len(i.FullToken) > 0 {
+		return i.FullToken
+	}
+
+	return i.Token
+}
@@ -0,0 +1,93 @@
+package brew
+
+import "strings"
+
+// The dependency types which can be combined into a dependency level, by name.
+var dependencyTypeNames = []struct {
+	name           string
+	dependencyType int
+}{
+	{"recommended", RecommendedDependency},
+	{"optional", OptionalDependency},
+	{"build", BuildDependency},
+}
+
+// Returns the dependency level with the given name. A single name is one of the
+// presets required, recommended, optional or build, each of which includes the
+// dependency types before it, while a comma separated list such as
+// "required,build" includes only the dependency types listed. Required
+// dependencies are included at every level.
+func ParseLevel(name string) (int, bool) {
+	names := strings.Split(strings.ToLower(name), ",")
+
+	if len(names) == 1 {
+		switch strings.TrimSpace(names[0]) {
+		case "required":
+			return Required, true
+		case "recommended":
+			return Recommended, true
+		case "optional":
+			return Optional, true
+		case "build":
+			return Build, true
+		}
+
+		return 0, false
+	}
+
+	level := Required
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		if n == "required" {
+			continue
+		}
+
+		found := false
+		for _, t := range dependencyTypeNames {
+			if t.name == n {
+				level |= t.dependencyType
+				found = true
+			}
+		}
+
+		if !found {
+			return 0, false
+		}
+	}
+
+	return level, true
+}
+
+// Returns the name of a dependency level, which is the name of its preset if
+// there is one, or the list of the dependency types it includes otherwise.
+func FormatLevel(level int) string {
+	switch level {
+	case Required:
+		return "required"
+	case Recommended:
+		return "recommended"
+	case Optional:
+		return "optional"
+	case Build:
+		return "build"
+	}
+
+	names := []string{"required"}
+	for _, t := range dependencyTypeNames {
+		if level&t.dependencyType != 0 {
+			names = append(names, t.name)
+		}
+	}
+
+	return strings.Join(names, ",")
+}
+
+// Returns the dependency level of an Entry, which is the level set for the entry
+// itself if there is one, or the given level otherwise.
+func (e *Entry) level(level int) int {
+	if l, ok := ParseLevel(e.Level); ok {
+		return l
+	}
+
+	return level
+}
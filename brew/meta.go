@@ -0,0 +1,190 @@
+package brew
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// Meta records how and when a Cache was built.
+type Meta struct {
+	// Version of the layout of the buckets of the Cache and of the info stored
+	// in them.
+	SchemaVersion int
+	// Time of the last refresh, or the zero time if the Cache was never refreshed
+	// by a version of bfm recording it.
+	RefreshedAt     time.Time
+	HomebrewVersion string
+	// Taps the Cache was refreshed from, with the commit each was at.
+	Taps []TapInfo
+}
+
+// TapInfo is a tap as listed by 'brew tap-info --json --installed'.
+type TapInfo struct {
+	Name   string `json:"name"`
+	Commit string `json:"HEAD"`
+}
+
+// Run the given commands for the version of Homebrew and the info of the
+// installed taps, parse their responses and store them in the BoltDB meta
+// bucket along with the current time and schema version.
+func (c *Cache) RefreshMeta(versionCommand, tapInfoCommand *exec.Cmd) error {
+	version, err := versionCommand.Output()
+	if err != nil {
+		return err
+	}
+
+	taps, err := InstalledTaps(tapInfoCommand)
+	if err != nil {
+		return err
+	}
+
+	firstLine := strings.SplitN(strings.TrimSpace(string(version)), "\n", 2)[0]
+	meta := Meta{
+		SchemaVersion:   SchemaVersion,
+		RefreshedAt:     time.Now(),
+		HomebrewVersion: strings.TrimPrefix(firstLine, "Homebrew "),
+		Taps:            taps,
+	}
+
+	return c.DB.Update(func(tx *bolt.Tx) error {
+		return putMeta(tx, meta)
+	})
+}
+
+// Run the given command for the info of the installed taps and parse its
+// response.
+func InstalledTaps(tapInfoCommand *exec.Cmd) ([]TapInfo, error) {
+	b, err := tapInfoCommand.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var taps []TapInfo
+	if err := json.Unmarshal(b, &taps); err != nil {
+		return nil, err
+	}
+
+	return taps, nil
+}
+
+// Returns the reasons a Cache is stale at the given time: it was refreshed
+// longer than the ttl ago, unless the ttl is zero, or taps have been tapped,
+// untapped or updated since, going by the installed taps given. A Cache which
+// was never refreshed with its Meta recorded is stale.
+func (m Meta) Staleness(ttl time.Duration, taps []TapInfo, now time.Time) []string {
+	if m.RefreshedAt.IsZero() {
+		return []string{"it has not been refreshed by this version of bfm"}
+	}
+
+	var reasons []string
+	if age := now.Sub(m.RefreshedAt); ttl > 0 && age > ttl {
+		reasons = append(reasons, fmt.Sprintf("it was last refreshed %s ago", age.Round(time.Minute)))
+	}
+
+	refreshed := make(map[string]string)
+	for _, t := range m.Taps {
+		refreshed[t.Name] = t.Commit
+	}
+
+	for _, t := range taps {
+		commit, present := refreshed[t.Name]
+		switch {
+		case !present:
+			reasons = append(reasons, fmt.Sprintf("%s has been tapped", t.Name))
+		case commit != t.Commit:
+			reasons = append(reasons, fmt.Sprintf("%s has been updated", t.Name))
+		}
+
+		delete(refreshed, t.Name)
+	}
+
+	for _, t := range m.Taps {
+		if _, untapped := refreshed[t.Name]; untapped {
+			reasons = append(reasons, fmt.Sprintf("%s has been untapped", t.Name))
+		}
+	}
+
+	return reasons
+}
+
+// Read the Meta of the Cache from the BoltDB meta bucket. Caches built before
+// the meta bucket was introduced are reported at the schema version their
+// buckets correspond to.
+func (c Cache) Meta() (Meta, error) {
+	var meta Meta
+
+	err := c.DB.View(func(tx *bolt.Tx) error {
+		m, err := getMeta(tx)
+		meta = m
+		return err
+	})
+
+	if err != nil {
+		return Meta{}, err
+	}
+
+	return meta, nil
+}
+
+func getMeta(tx *bolt.Tx) (Meta, error) {
+	b := tx.Bucket([]byte("meta"))
+	if b == nil {
+		return Meta{SchemaVersion: unversionedSchema(tx)}, nil
+	}
+
+	var meta Meta
+	version, err := strconv.Atoi(string(b.Get([]byte("schema_version"))))
+	if err != nil {
+		return Meta{}, fmt.Errorf("read schema version: %s", err)
+	}
+
+	meta.SchemaVersion = version
+	meta.HomebrewVersion = string(b.Get([]byte("homebrew_version")))
+
+	if v := b.Get([]byte("refreshed_at")); v != nil {
+		if meta.RefreshedAt, err = time.Parse(time.RFC3339, string(v)); err != nil {
+			return Meta{}, fmt.Errorf("read refresh time: %s", err)
+		}
+	}
+
+	if v := b.Get([]byte("taps")); v != nil {
+		if err := json.Unmarshal(v, &meta.Taps); err != nil {
+			return Meta{}, err
+		}
+	}
+
+	return meta, nil
+}
+
+func putMeta(tx *bolt.Tx, meta Meta) error {
+	b, err := tx.CreateBucketIfNotExists([]byte("meta"))
+	if err != nil {
+		return fmt.Errorf("create bucket: %s", err)
+	}
+
+	taps, err := json.Marshal(meta.Taps)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]string{
+		"schema_version":   strconv.Itoa(meta.SchemaVersion),
+		"refreshed_at":     meta.RefreshedAt.Format(time.RFC3339),
+		"homebrew_version": meta.HomebrewVersion,
+		"taps":             string(taps),
+	}
+
+	for key, value := range values {
+		if err := b.Put([]byte(key), []byte(value)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
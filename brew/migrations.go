@@ -0,0 +1,156 @@
+package brew
+
+import (
+	"encoding/json"
+
+	"github.com/boltdb/bolt"
+)
+
+// SchemaVersion is the version of the layout of the buckets of a Cache written by
+// this version of bfm. It is raised along with a migration whenever a bucket is
+// added or the shape of the info stored in one changes.
+const SchemaVersion = 5
+
+// A migration upgrades a Cache from the schema version before it to its own. A
+// migration without a migrate func cannot be done in place, and the Cache has
+// to be rebuilt from scratch.
+type migration struct {
+	version int
+	migrate func(tx *bolt.Tx) error
+}
+
+var migrations = []migration{
+	// Aliases and old names of formulae are indexed in the alias bucket, from
+	// fields of the info of formulae which were not stored before.
+	{version: 2},
+	// The info of every cask is stored in the cask_info bucket, which needs
+	// info Homebrew was not asked for before.
+	{version: 3},
+	// The meta bucket records how and when the Cache was built.
+	{version: 4, migrate: func(tx *bolt.Tx) error { return nil }},
+	// Casks are stored by their full tokens in the cask_info bucket, and the
+	// casks from taps other than homebrew/cask indexed by their bare tokens in the
+	// cask_token bucket.
+	{version: 5, migrate: rekeyCaskInfo},
+}
+
+// Bring a Cache built by an older version of bfm up to the current schema
+// version, upgrading it in place where the info it holds allows. Otherwise every
+// bucket is dropped and rebuild is true, and the Cache has to be refreshed.
+func (c *Cache) Migrate() (rebuild bool, err error) {
+	err = c.DB.Update(func(tx *bolt.Tx) error {
+		meta, err := getMeta(tx)
+		if err != nil {
+			return err
+		}
+
+		if meta.SchemaVersion > SchemaVersion {
+			return ErrNewerCache(meta.SchemaVersion)
+		}
+
+		if meta.SchemaVersion == SchemaVersion && tx.Bucket([]byte("meta")) != nil {
+			return nil
+		}
+
+		for _, m := range migrations {
+			if m.version <= meta.SchemaVersion {
+				continue
+			}
+
+			if m.migrate == nil {
+				rebuild = true
+				break
+			}
+
+			if err := m.migrate(tx); err != nil {
+				return err
+			}
+		}
+
+		if rebuild {
+			if err := dropBuckets(tx); err != nil {
+				return err
+			}
+
+			meta = Meta{}
+		}
+
+		meta.SchemaVersion = SchemaVersion
+		return putMeta(tx, meta)
+	})
+
+	return rebuild, err
+}
+
+// Returns the schema version of a Cache built before the meta bucket was
+// introduced, going by the buckets it has. A Cache without any buckets was
+// never refreshed and is at the current version.
+func unversionedSchema(tx *bolt.Tx) int {
+	switch {
+	case tx.Bucket([]byte("brew")) == nil:
+		return SchemaVersion
+	case tx.Bucket([]byte("alias")) == nil:
+		return 1
+	case tx.Bucket([]byte("cask_info")) == nil:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func dropBuckets(tx *bolt.Tx) error {
+	var names [][]byte
+	if err := tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+		names = append(names, append([]byte{}, name...))
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := tx.DeleteBucket(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stores the info of every cask in the cask_info bucket under its full token
+// rather than its bare token, and indexes the bare tokens of the casks from taps
+// other than homebrew/cask.
+func rekeyCaskInfo(tx *bolt.Tx) error {
+	b := tx.Bucket([]byte("cask_info"))
+	if b == nil {
+		return nil
+	}
+
+	rekeyed := make(map[string]string)
+	if err := b.ForEach(func(k, v []byte) error {
+		var cask CaskInfo
+		if err := json.Unmarshal(v, &cask); err != nil {
+			return err
+		}
+
+		if cask.fullToken() != string(k) {
+			rekeyed[string(k)] = cask.fullToken()
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for token, fullToken := range rekeyed {
+		v := append([]byte{}, b.Get([]byte(token))...)
+		if err := b.Delete([]byte(token)); err != nil {
+			return err
+		}
+
+		if err := b.Put([]byte(fullToken), v); err != nil {
+			return err
+		}
+	}
+
+	return indexCaskTokens(tx)
+}
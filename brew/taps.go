@@ -0,0 +1,69 @@
+package brew
+
+import (
+	"sort"
+	"strings"
+)
+
+// Returns the tap a package has to be tapped from before it can be installed,
+// or an empty string for packages from the taps Homebrew comes with.
+func RequiredTap(name string) string {
+	switch t := tap(name); t {
+	case "homebrew/core", "homebrew/cask":
+		return ""
+	default:
+		return t
+	}
+}
+
+// Reports whether two tap names refer to the same tap, ignoring case and the
+// homebrew- prefix of the repository, which Homebrew leaves out of tap names.
+func SameTap(a, b string) bool {
+	normalise := func(t string) string {
+		return strings.Replace(strings.ToLower(t), "/homebrew-", "/", 1)
+	}
+
+	return normalise(a) == normalise(b)
+}
+
+// Returns the taps the brews and casks in the CacheMap have to be tapped from.
+func (c CacheMap) Taps() []string {
+	var taps []string
+	for _, m := range []Map{c.Map, c.Casks} {
+		for name := range m {
+			if t := RequiredTap(name); len(t) > 0 && !containsTap(taps, t) {
+				taps = append(taps, t)
+			}
+		}
+	}
+
+	sort.Strings(taps)
+	return taps
+}
+
+// Returns the brews and casks in the CacheMap which come from the given tap.
+func (c CacheMap) FromTap(t string) (brews, casks []string) {
+	for _, name := range c.names() {
+		if SameTap(RequiredTap(name), t) {
+			brews = append(brews, name)
+		}
+	}
+
+	for _, name := range c.Casks.names() {
+		if SameTap(RequiredTap(name), t) {
+			casks = append(casks, name)
+		}
+	}
+
+	return brews, casks
+}
+
+func containsTap(taps []string, t string) bool {
+	for _, existing := range taps {
+		if SameTap(existing, t) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,106 @@
+package brew
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/LGUG2Z/bfm/graph"
+)
+
+// Returns the packages linked to each package by the dependencies resolved by
+// ResolveDependencyMap, which are the dependencies of each package, or in reverse
+// the packages depending on it, sorted by name.
+func (c CacheMap) links(reverse bool) map[string][]Link {
+	links := make(map[string][]Link)
+	for _, m := range []Map{c.Map, c.Casks} {
+		for name, e := range m {
+			for _, dependencyType := range dependencyTypes {
+				for _, d := range *e.dependents(dependencyType) {
+					if reverse {
+						links[name] = append(links[name], Link{Name: d, DependencyType: dependencyType})
+					} else {
+						links[d] = append(links[d], Link{Name: name, DependencyType: dependencyType})
+					}
+				}
+			}
+		}
+	}
+
+	for _, l := range links {
+		sort.Slice(l, func(i, j int) bool {
+			if l[i].Name != l[j].Name {
+				return l[i].Name < l[j].Name
+			}
+
+			return l[i].DependencyType < l[j].DependencyType
+		})
+	}
+
+	return links
+}
+
+// Returns the dependencies resolved by ResolveDependencyMap as an indented tree,
+// with every primary brew, or the root given, at the top and the dependencies of
+// each brew nested beneath it, marked with their type. In reverse, every brew
+// without dependencies of its own which other brews depend on, or the root given,
+// is at the top with the brews depending on it nested beneath it. The links of a
+// brew are only written the first time the brew appears, and marked "(see above)"
+// every other time.
+func (c CacheMap) Tree(root string, reverse bool) string {
+	links := c.links(reverse)
+
+	var roots []string
+	if len(root) > 0 {
+		roots = append(roots, root)
+	} else {
+		linked := make(map[string]bool)
+		for _, l := range links {
+			for _, link := range l {
+				linked[link.Name] = true
+			}
+		}
+
+		for _, m := range []Map{c.Map, c.Casks} {
+			for name := range m {
+				if !linked[name] && (!reverse || len(links[name]) > 0) {
+					roots = append(roots, name)
+				}
+			}
+		}
+
+		sort.Strings(roots)
+	}
+
+	var b bytes.Buffer
+	written := make(map[string]bool)
+
+	var write func(name, prefix string)
+	write = func(name, prefix string) {
+		for i, l := range links[name] {
+			branch, indent := "├── ", "│   "
+			if i == len(links[name])-1 {
+				branch, indent = "└── ", "    "
+			}
+
+			fmt.Fprintf(&b, "%s%s%s (%s)", prefix, branch, l.Name, graph.Kind(l.DependencyType))
+
+			if written[l.Name] && len(links[l.Name]) > 0 {
+				b.WriteString(" (see above)\n")
+				continue
+			}
+
+			b.WriteString("\n")
+			written[l.Name] = true
+			write(l.Name, prefix+indent)
+		}
+	}
+
+	for _, r := range roots {
+		fmt.Fprintln(&b, r)
+		written[r] = true
+		write(r, "")
+	}
+
+	return b.String()
+}
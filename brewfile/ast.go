@@ -0,0 +1,263 @@
+package brewfile
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/LGUG2Z/bfm/diagnostics"
+)
+
+// Pos is a position in a Brewfile. Lines and columns start at 1.
+type Pos = diagnostics.Position
+
+// A Node is an element of the syntax tree of a Brewfile.
+type Node interface {
+	Pos() Pos
+}
+
+// A Value is a literal or expression used as an argument or option value.
+// String returns the value formatted as Ruby source.
+type Value interface {
+	Node
+	String() string
+}
+
+// File is the syntax tree of a single Brewfile.
+type File struct {
+	Path  string
+	Nodes []Node
+}
+
+// Call is a DSL method call such as `brew 'vim', args: ['HEAD']`. Options are
+// the trailing hash arguments in the order they were written, and Comment is
+// the text of a comment on the same line as the end of the call. Source is the
+// call as written, without a modifier or comment, for parsed calls.
+type Call struct {
+	Position Pos
+	Name     string
+	Args     []Value
+	Options  []Option
+	Comment  string
+	Source   string
+}
+
+// Option is a single `key: value` (or `:key => value`) pair.
+type Option struct {
+	Position Pos
+	Key      string
+	Value    Value
+}
+
+// CommentLine is a comment on a line of its own.
+type CommentLine struct {
+	Position Pos
+	Text     string
+}
+
+// BlankLine is an empty line separating groups of statements.
+type BlankLine struct {
+	Position Pos
+}
+
+// Conditional is an `if`/`unless` block, or a call followed by an `if`/`unless`
+// modifier, with the condition kept as the Ruby source it was written as.
+type Conditional struct {
+	Position  Pos
+	Keyword   string
+	Condition string
+	Body      []Node
+	Else      []Node
+	Modifier  bool
+}
+
+// StringLit is a single or double quoted string. An interpolated string is
+// written back in double quotes with its interpolation kept.
+type StringLit struct {
+	Position     Pos
+	Value        string
+	Quote        byte
+	Interpolated bool
+}
+
+// SymbolLit is a symbol such as :changed.
+type SymbolLit struct {
+	Position Pos
+	Name     string
+}
+
+// NumberLit is an integer or float literal.
+type NumberLit struct {
+	Position Pos
+	Text     string
+}
+
+// BoolLit is one of true or false.
+type BoolLit struct {
+	Position Pos
+	Value    bool
+}
+
+// ArrayLit is a list of values between square brackets.
+type ArrayLit struct {
+	Position Pos
+	Elements []Value
+}
+
+// HashLit is a list of options between curly braces.
+type HashLit struct {
+	Position Pos
+	Entries  []Option
+}
+
+// Expr is any other Ruby expression, such as `ENV['HOME']` or `File.read("x")`,
+// kept as the source it was written as.
+type Expr struct {
+	Position Pos
+	Source   string
+}
+
+func (n *Call) Pos() Pos        { return n.Position }
+func (n *Option) Pos() Pos      { return n.Position }
+func (n *CommentLine) Pos() Pos { return n.Position }
+func (n *BlankLine) Pos() Pos   { return n.Position }
+func (n *Conditional) Pos() Pos { return n.Position }
+func (n *StringLit) Pos() Pos   { return n.Position }
+func (n *SymbolLit) Pos() Pos   { return n.Position }
+func (n *NumberLit) Pos() Pos   { return n.Position }
+func (n *BoolLit) Pos() Pos     { return n.Position }
+func (n *ArrayLit) Pos() Pos    { return n.Position }
+func (n *HashLit) Pos() Pos     { return n.Position }
+func (n *Expr) Pos() Pos        { return n.Position }
+
+func (n *StringLit) String() string {
+	if n.Interpolated {
+		return InterpolatedQuote(n.Value)
+	}
+
+	return Quote(n.Value)
+}
+
+func (n *SymbolLit) String() string {
+	return ":" + n.Name
+}
+
+func (n *NumberLit) String() string {
+	return n.Text
+}
+
+func (n *BoolLit) String() string {
+	return fmt.Sprintf("%t", n.Value)
+}
+
+func (n *ArrayLit) String() string {
+	elements := make([]string, len(n.Elements))
+	for i, e := range n.Elements {
+		elements[i] = e.String()
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+func (n *HashLit) String() string {
+	return "{ " + formatOptions(n.Entries) + " }"
+}
+
+func (n *Expr) String() string {
+	return n.Source
+}
+
+// Formats a Call as a single Brewfile line, normalising quotes, spacing and
+// multi-line options.
+func (n *Call) String() string {
+	parts := make([]string, 0, len(n.Args)+1)
+	for _, a := range n.Args {
+		parts = append(parts, a.String())
+	}
+
+	if len(n.Options) > 0 {
+		parts = append(parts, formatOptions(n.Options))
+	}
+
+	line := n.Name
+	if len(parts) > 0 {
+		line = fmt.Sprintf("%s %s", n.Name, strings.Join(parts, ", "))
+	}
+
+	if comment := strings.TrimSpace(n.Comment); len(comment) > 0 {
+		line = fmt.Sprintf("%s # %s", line, comment)
+	}
+
+	return line
+}
+
+// Returns the value of the first argument of a Call if it is a string.
+func (n *Call) FirstString() (string, bool) {
+	if len(n.Args) < 1 {
+		return "", false
+	}
+
+	s, ok := n.Args[0].(*StringLit)
+	if !ok {
+		return "", false
+	}
+
+	return s.Value, true
+}
+
+// Calls fn for every Call in the given nodes, descending into blocks.
+func Walk(nodes []Node, fn func(*Call)) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *Call:
+			fn(n)
+		case *Conditional:
+			Walk(n.Body, fn)
+			Walk(n.Else, fn)
+		}
+	}
+}
+
+// Quotes a string as a single quoted Ruby string.
+func Quote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `'`, `\'`, -1)
+	return "'" + s + "'"
+}
+
+// Quotes a string as a double quoted Ruby string, escaping interpolation.
+func DoubleQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	s = strings.Replace(s, `#{`, `\#{`, -1)
+	return `"` + s + `"`
+}
+
+// Quotes a string as a double quoted Ruby string, keeping its interpolation.
+func InterpolatedQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + s + `"`
+}
+
+func formatOptions(options []Option) string {
+	formatted := make([]string, len(options))
+	for i, o := range options {
+		if isLabel(o.Key) {
+			formatted[i] = fmt.Sprintf("%s: %s", o.Key, o.Value.String())
+		} else {
+			formatted[i] = fmt.Sprintf("%s => %s", Quote(o.Key), o.Value.String())
+		}
+	}
+
+	return strings.Join(formatted, ", ")
+}
+
+func isLabel(key string) bool {
+	for i, r := range key {
+		if !isIdentPart(r) || (i == 0 && !isIdentStart(r)) {
+			return false
+		}
+	}
+
+	return len(key) > 0
+}
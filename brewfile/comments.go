@@ -0,0 +1,150 @@
+package brewfile
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	levelMarker    = regexp.MustCompile(`\[level: ((?:required|recommended|optional|build)(?:,(?:required|recommended|optional|build))*)\]`)
+	explicitMarker = regexp.MustCompile(`\[explicit\]`)
+)
+
+// Comments holds the comments of a Brewfile which do not belong to a single
+// entry and are written back out when the Brewfile is rewritten in round-trip
+// mode.
+type Comments struct {
+	// Comment lines at the top of the Brewfile, separated from the first entry
+	// by a blank line.
+	Header []string
+	// Comment lines after the last entry of the Brewfile.
+	Footer []string
+	// Commented-out entries such as "# brew 'emacs'", by package type.
+	Disabled map[string]Entries
+}
+
+func NewComments() *Comments {
+	return &Comments{Disabled: make(map[string]Entries)}
+}
+
+// Returns the key identifying an entry of the given package type and name.
+func Key(packageType, name string) string {
+	return packageType + " " + Quote(name)
+}
+
+// Splits the comment of an entry into the annotations generated by bfm and the
+// free-form text written by the user.
+func SplitComment(comment string) (annotation, user string) {
+	return DefaultLayout().SplitComment(comment)
+}
+
+// Takes a "[level: build]" marker out of the comment of an entry, returning the
+// level it sets and the rest of the comment.
+func splitLevel(comment string) (level, rest string) {
+	groups := levelMarker.FindStringSubmatch(comment)
+	if groups == nil {
+		return "", comment
+	}
+
+	rest = strings.Replace(comment, groups[0], "", 1)
+	return groups[1], strings.Join(strings.Fields(rest), " ")
+}
+
+// Takes an "[explicit]" marker out of the comment of an entry, reporting whether
+// it was there and returning the rest of the comment.
+func splitExplicit(comment string) (explicit bool, rest string) {
+	if !explicitMarker.MatchString(comment) {
+		return false, comment
+	}
+
+	rest = explicitMarker.ReplaceAllString(comment, "")
+	return true, strings.Join(strings.Fields(rest), " ")
+}
+
+// Walks the nodes of a parsed Brewfile in source order, calling fn for every
+// entry and commented-out entry with the comment lines written above it and the
+// condition of the block it is in, include for every included Brewfile and
+// statement for every other call. Comment lines are attached to the next entry,
+// include or statement, even across blank
+// lines, unless they open the file and are followed by a blank line, in which
+// case they are the header. Included paths are resolved against dir.
+func (c *Comments) collect(nodes []Node, dir string, fn func(entry Entry, doc []string), include func(Include), statement func(Statement)) {
+	var pending []string
+	seenEntry := false
+
+	flatten(nodes, "", func(n Node, condition string) {
+		switch n := n.(type) {
+		case *BlankLine:
+			if !seenEntry && len(c.Header) < 1 && len(pending) > 0 {
+				c.Header, pending = pending, nil
+			}
+		case *CommentLine:
+			if entry, ok := parseEntryLine(n.Text); ok {
+				entry.Disabled, entry.Pos, entry.Condition = true, n.Position, condition
+				fn(entry, pending)
+				pending, seenEntry = nil, true
+				return
+			}
+
+			pending = append(pending, "#"+n.Text)
+		case *Call:
+			if entry, ok := NewEntry(n); ok {
+				entry.Condition = condition
+				fn(entry, pending)
+				seenEntry = true
+			} else if i, ok := NewInclude(n, dir); ok {
+				i.Condition, i.Doc = condition, pending
+				include(i)
+				seenEntry = true
+			} else {
+				s := NewStatement(n)
+				s.Condition, s.Doc = condition, pending
+				statement(s)
+				seenEntry = true
+			}
+
+			pending = nil
+		}
+	})
+
+	c.Footer = pending
+}
+
+// Parses the text of a comment as a single entry, to recognise commented-out
+// entries such as "# brew 'emacs'".
+func parseEntryLine(text string) (Entry, bool) {
+	file, err := Parse("", []byte(strings.TrimSpace(text)))
+	if err != nil || len(file.Nodes) != 1 {
+		return Entry{}, false
+	}
+
+	call, ok := file.Nodes[0].(*Call)
+	if !ok {
+		return Entry{}, false
+	}
+
+	return NewEntry(call)
+}
+
+func isPackageType(name string) bool {
+	switch name {
+	case "tap", "brew", "cask", "mas", "whalebrew", "vscode":
+		return true
+	}
+
+	return false
+}
+
+// Calls fn for every node in the given nodes in source order with the condition
+// under which it applies, descending into blocks.
+func flatten(nodes []Node, condition string, fn func(n Node, condition string)) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *Conditional:
+			flatten(n.Body, Nest(condition, n.Header()), fn)
+			flatten(n.Else, Nest(condition, Negate(n.Header())), fn)
+		default:
+			fn(n, condition)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package brewfile
+
+import (
+	"strings"
+)
+
+// Returns the condition of a block as it opens the block in a Brewfile, e.g.
+// "if OS.mac?" or "unless ENV['CI']".
+func (n *Conditional) Header() string {
+	return n.Keyword + " " + n.Condition
+}
+
+// Returns the condition a flag value stands for, where mac and linux are
+// shorthands for OS.mac? and OS.linux? and any other value is used as the Ruby
+// expression of an `if` block.
+func ConditionFor(value string) string {
+	value = strings.TrimSpace(value)
+
+	switch {
+	case value == "":
+		return ""
+	case value == "mac":
+		return "if OS.mac?"
+	case value == "linux":
+		return "if OS.linux?"
+	case strings.HasPrefix(value, "if "), strings.HasPrefix(value, "unless "):
+		return value
+	}
+
+	return "if " + value
+}
+
+// Returns the opposite of a condition, which applies to the else branch of a
+// block.
+func Negate(condition string) string {
+	keyword, e := splitCondition(condition)
+	if keyword == "if" {
+		return "unless " + e
+	}
+
+	return "if " + e
+}
+
+// Combines the condition of a block with the condition of a block nested
+// inside it.
+func Nest(outer, inner string) string {
+	if len(outer) < 1 {
+		return inner
+	}
+
+	if len(inner) < 1 {
+		return outer
+	}
+
+	return "if " + asExpression(outer) + " && " + asExpression(inner)
+}
+
+// Returns the condition as a single Ruby expression which is true when the
+// condition holds.
+func asExpression(condition string) string {
+	keyword, e := splitCondition(condition)
+	if strings.ContainsAny(e, " ") {
+		e = "(" + e + ")"
+	}
+
+	if keyword == "unless" {
+		return "!" + e
+	}
+
+	return e
+}
+
+func splitCondition(condition string) (keyword, e string) {
+	parts := strings.SplitN(condition, " ", 2)
+	if len(parts) < 2 {
+		return "if", condition
+	}
+
+	return parts[0], parts[1]
+}
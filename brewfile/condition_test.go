@@ -0,0 +1,30 @@
+package brewfile_test
+
+import (
+	. "github.com/LGUG2Z/bfm/brewfile"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Condition", func() {
+	It("Maps the values of the --when flag to block conditions", func() {
+		Expect(ConditionFor("")).To(Equal(""))
+		Expect(ConditionFor("mac")).To(Equal("if OS.mac?"))
+		Expect(ConditionFor("linux")).To(Equal("if OS.linux?"))
+		Expect(ConditionFor("ENV['CI']")).To(Equal("if ENV['CI']"))
+		Expect(ConditionFor("unless ENV['CI']")).To(Equal("unless ENV['CI']"))
+	})
+
+	It("Negates conditions for else branches", func() {
+		Expect(Negate("if OS.mac?")).To(Equal("unless OS.mac?"))
+		Expect(Negate("unless OS.mac?")).To(Equal("if OS.mac?"))
+	})
+
+	It("Combines the conditions of nested blocks", func() {
+		Expect(Nest("", "if OS.mac?")).To(Equal("if OS.mac?"))
+		Expect(Nest("if OS.mac?", "")).To(Equal("if OS.mac?"))
+		Expect(Nest("if OS.mac?", "unless ENV['CI']")).To(Equal("if OS.mac? && !ENV['CI']"))
+		Expect(Nest("unless OS.mac?", "if ENV['CI'] == 'true'")).To(Equal("if !OS.mac? && (ENV['CI'] == 'true')"))
+	})
+})
@@ -0,0 +1,27 @@
+package brewfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrIncludeCycle = func(paths []string) error {
+		return fmt.Errorf("Include cycle: %s.", strings.Join(paths, " -> "))
+	}
+	ErrNotIncluded = func(path string) error {
+		return fmt.Errorf("%s is not the Brewfile or included by it.", path)
+	}
+	ErrUnknownSection = func(name string) error {
+		return fmt.Errorf("Unknown layout section %s. Sections are %s.", name, strings.Join(Sections, ", "))
+	}
+	ErrInvalidQuoteStyle = func(quote string) error {
+		return fmt.Errorf("Invalid layout quote style %s. Use single or double.", quote)
+	}
+	ErrInvalidBlankLines = func(n int) error {
+		return fmt.Errorf("Invalid number of blank lines between sections: %d.", n)
+	}
+	ErrInvalidAnnotation = func(reason string) error {
+		return fmt.Errorf("Invalid layout annotation template: %s.", reason)
+	}
+)
@@ -0,0 +1,278 @@
+package brewfile
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/LGUG2Z/bfm/diagnostics"
+)
+
+// Entry is a single tap, brew, cask, mas, whalebrew or vscode line of a Brewfile.
+type Entry struct {
+	Type    string
+	Name    string
+	Args    []Value
+	Options Options
+	// Dependency annotations generated by bfm, e.g. "[required by: vim]".
+	Annotation string
+	// Dependency level set for the entry itself with a "[level: build]" marker,
+	// overriding the level set for the Brewfile.
+	Level string
+	// Set with an "[explicit]" marker for entries whitelisted on purpose, which
+	// stay in the primary section and are kept when the packages depending on
+	// them are removed.
+	Explicit bool
+	// Free-form comment written by the user on the same line as the entry.
+	Comment string
+	// Comment lines written by the user directly above the entry.
+	Doc []string
+	// Set for commented-out entries such as "# brew 'emacs'".
+	Disabled bool
+	// Condition of the block the entry is written in, e.g. "if OS.mac?", or empty
+	// for entries outside of any block.
+	Condition string
+	// Path of the included Brewfile the entry is written in, or empty for the
+	// Brewfile itself.
+	File string
+	Pos  Pos
+}
+
+// Creates an Entry from a parsed call, reporting false if the call is not a
+// package entry with a quoted name.
+func NewEntry(call *Call) (Entry, bool) {
+	name, ok := call.FirstString()
+	if !ok || !isPackageType(call.Name) {
+		return Entry{}, false
+	}
+
+	annotation, comment := SplitComment(call.Comment)
+	level, comment := splitLevel(comment)
+	explicit, comment := splitExplicit(comment)
+
+	return Entry{
+		Type:       call.Name,
+		Name:       name,
+		Args:       call.Args[1:],
+		Options:    append(Options{}, call.Options...),
+		Annotation: annotation,
+		Level:      level,
+		Explicit:   explicit,
+		Comment:    comment,
+		Pos:        call.Position,
+	}, true
+}
+
+// Returns the key identifying the entry, e.g. "brew 'vim'".
+func (e Entry) Key() string {
+	return Key(e.Type, e.Name)
+}
+
+// Formats the entry as a Brewfile line. Leading comment lines are not included.
+func (e Entry) String() string {
+	if e.Disabled {
+		return "# " + e.call().String()
+	}
+
+	return e.call().String()
+}
+
+// Returns the entry as a Call, with its annotation, markers and comment on the
+// same line.
+func (e Entry) call() *Call {
+	call := &Call{Name: e.Type, Args: append([]Value{&StringLit{Value: e.Name}}, e.Args...), Options: append([]Option{}, e.Options...)}
+
+	var comments []string
+	if len(e.Annotation) > 0 {
+		comments = append(comments, e.Annotation)
+	}
+
+	if len(e.Level) > 0 {
+		comments = append(comments, "[level: "+e.Level+"]")
+	}
+
+	if e.Explicit {
+		comments = append(comments, "[explicit]")
+	}
+
+	if len(e.Comment) > 0 {
+		comments = append(comments, e.Comment)
+	}
+
+	call.Comment = strings.Join(comments, " ")
+	return call
+}
+
+// Entries is a list of Brewfile entries of the same package type.
+type Entries []Entry
+
+// Reports whether an entry with the given name is in the list.
+func (entries Entries) Contains(name string) bool {
+	_, ok := entries.Find(name)
+	return ok
+}
+
+// Returns the entry with the given name.
+func (entries Entries) Find(name string) (Entry, bool) {
+	for _, e := range entries {
+		if e.Name == name {
+			return e, true
+		}
+	}
+
+	return Entry{}, false
+}
+
+// Returns the list without the entry of the given name.
+func (entries Entries) Remove(name string) Entries {
+	var remaining Entries
+	for _, e := range entries {
+		if e.Name != name {
+			remaining = append(remaining, e)
+		}
+	}
+
+	return remaining
+}
+
+// Returns the entries written in a block with the given condition, or outside
+// of any block for an empty condition.
+func (entries Entries) When(condition string) Entries {
+	var matching Entries
+	for _, e := range entries {
+		if e.Condition == condition {
+			matching = append(matching, e)
+		}
+	}
+
+	return matching
+}
+
+// Returns the entries written in the included Brewfile at the given path, or in
+// the Brewfile itself for an empty path.
+func (entries Entries) In(file string) Entries {
+	var matching Entries
+	for _, e := range entries {
+		if e.File == file {
+			matching = append(matching, e)
+		}
+	}
+
+	return matching
+}
+
+// Sorts the list alphabetically by name.
+func (entries Entries) Sort() {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+}
+
+// Returns each entry formatted as a Brewfile line.
+func (entries Entries) Lines() []string {
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		lines[i] = e.String()
+	}
+
+	return lines
+}
+
+// Options is an ordered map of the options of an entry, such as
+// `args: ['HEAD']` or `id: 497799835`.
+type Options []Option
+
+// Returns the value of the option with the given key.
+func (o Options) Get(key string) (Value, bool) {
+	for _, option := range o {
+		if option.Key == key {
+			return option.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// Returns the options with the given key set to a value, replacing an existing
+// option in place or adding it at the end.
+func (o Options) Set(key string, value Value) Options {
+	for i, option := range o {
+		if option.Key == key {
+			updated := append(Options{}, o...)
+			updated[i].Value = value
+			return updated
+		}
+	}
+
+	return append(append(Options{}, o...), Option{Key: key, Value: value})
+}
+
+// Returns the options without the option with the given key.
+func (o Options) Delete(key string) Options {
+	var remaining Options
+	for _, option := range o {
+		if option.Key != key {
+			remaining = append(remaining, option)
+		}
+	}
+
+	return remaining
+}
+
+// Returns the keys of the options in order.
+func (o Options) Keys() []string {
+	keys := make([]string, len(o))
+	for i, option := range o {
+		keys[i] = option.Key
+	}
+
+	return keys
+}
+
+// Returns a list of strings as a Ruby array value.
+func StringArray(values []string) Value {
+	array := &ArrayLit{}
+	for _, v := range values {
+		array.Elements = append(array.Elements, &StringLit{Value: v})
+	}
+
+	return array
+}
+
+// Returns the strings of a string or array of strings value which interpolate
+// Ruby code.
+func InterpolatedValues(v Value) []string {
+	var values []string
+	switch v := v.(type) {
+	case *StringLit:
+		if v.Interpolated {
+			values = append(values, v.Value)
+		}
+	case *ArrayLit:
+		for _, e := range v.Elements {
+			values = append(values, InterpolatedValues(e)...)
+		}
+	}
+
+	return values
+}
+
+// Returns the strings of a string or array of strings value.
+func StringValues(v Value) ([]string, error) {
+	switch v := v.(type) {
+	case *StringLit:
+		return []string{v.Value}, nil
+	case *ArrayLit:
+		var values []string
+		for _, e := range v.Elements {
+			s, ok := e.(*StringLit)
+			if !ok {
+				return nil, diagnostics.Errorf(e.Pos(), "expected a string, found %s", e.String())
+			}
+
+			values = append(values, s.Value)
+		}
+		return values, nil
+	}
+
+	return nil, diagnostics.Errorf(v.Pos(), "expected a string or an array of strings, found %s", v.String())
+}
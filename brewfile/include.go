@@ -0,0 +1,62 @@
+package brewfile
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var includeRegexp = regexp.MustCompile(`^File\.read\(.*?(['"])([^'"]+)['"].*\)$`)
+
+// Include is a line evaluating another Brewfile, such as
+// `instance_eval(File.read("Brewfile.work"))`.
+type Include struct {
+	// The path of the included Brewfile as written.
+	Name string
+	// The path of the included Brewfile relative to the working directory,
+	// resolved against the directory of the including Brewfile.
+	Path string
+	// The line as written, without its comment.
+	Source    string
+	Comment   string
+	Doc       []string
+	Condition string
+	Pos       Pos
+}
+
+// Creates an Include from a parsed call, reporting false if the call does not
+// read and evaluate another file. Relative paths are resolved against the
+// directory of the including Brewfile.
+func NewInclude(call *Call, dir string) (Include, bool) {
+	if call.Name != "instance_eval" && call.Name != "eval" || len(call.Args) != 1 || len(call.Options) > 0 {
+		return Include{}, false
+	}
+
+	match := includeRegexp.FindStringSubmatch(call.Args[0].String())
+	if match == nil {
+		return Include{}, false
+	}
+
+	name := match[2]
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	return Include{
+		Name:    name,
+		Path:    filepath.Clean(path),
+		Source:  call.Name + "(" + call.Args[0].String() + ")",
+		Comment: strings.TrimSpace(call.Comment),
+		Pos:     call.Position,
+	}, true
+}
+
+// Formats the include as a Brewfile line. Leading comment lines are not included.
+func (i Include) String() string {
+	if len(i.Comment) > 0 {
+		return i.Source + " # " + i.Comment
+	}
+
+	return i.Source
+}
@@ -0,0 +1,34 @@
+package brewfile_test
+
+import (
+	. "github.com/LGUG2Z/bfm/brewfile"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Include", func() {
+	It("Recognises the ways Brewfiles include other Brewfiles", func() {
+		file, err := Parse("dotfiles/Brewfile", []byte(`instance_eval(File.read("Brewfile.work"))
+instance_eval File.read('/etc/Brewfile') # shared
+eval(File.read(File.join(__dir__, 'Brewfile.mac')))
+instance_eval(ENV['BREWFILE'])
+`))
+		Expect(err).ToNot(HaveOccurred())
+
+		var includes []Include
+		Walk(file.Nodes, func(call *Call) {
+			if include, ok := NewInclude(call, "dotfiles"); ok {
+				includes = append(includes, include)
+			}
+		})
+
+		Expect(includes).To(HaveLen(3))
+		Expect(includes[0].Name).To(Equal("Brewfile.work"))
+		Expect(includes[0].Path).To(Equal("dotfiles/Brewfile.work"))
+		Expect(includes[0].String()).To(Equal(`instance_eval(File.read("Brewfile.work"))`))
+		Expect(includes[1].Path).To(Equal("/etc/Brewfile"))
+		Expect(includes[1].String()).To(Equal(`instance_eval(File.read('/etc/Brewfile')) # shared`))
+		Expect(includes[2].Path).To(Equal("dotfiles/Brewfile.mac"))
+	})
+})
@@ -0,0 +1,422 @@
+package brewfile
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"text/template"
+
+	. "github.com/LGUG2Z/bfm/helpers"
+)
+
+// The annotation written by default for brews which are dependencies of other
+// brews, e.g. "[required by: neovim] [build for: vim]".
+const DefaultAnnotation = `{{- if .RequiredBy }} [required by: {{ StringsJoin .RequiredBy ", " }}] {{- end -}}
+
+	{{- if .RecommendedFor }} [recommended for: {{ StringsJoin .RecommendedFor ", " }}] {{- end -}}
+
+	{{- if .OptionalFor }} [optional for: {{ StringsJoin .OptionalFor ", " }}] {{- end -}}
+
+	{{- if .BuildOf }} [build for: {{ StringsJoin .BuildOf ", " }}] {{- end -}}`
+
+// The names of the sections of a Brewfile, in the order they are written by
+// default. Dependent brews are the brews annotated as dependencies of others.
+var Sections = []string{"tap", "brew", "dependent", "cask", "mas", "whalebrew", "vscode", "include"}
+
+// Matches the name of a package in an annotation.
+const annotatedName = `[\w@/.+-]+`
+
+// Layout controls how a Brewfile is written out.
+type Layout struct {
+	// Order of the sections. Sections which are not listed are written after
+	// the listed ones, in the default order.
+	Sections []string
+	// Comment written above a section when it has entries, by section name.
+	Headers map[string]string
+	// Number of blank lines between sections.
+	BlankLines int
+	// Quotes used for strings, either "single" or "double".
+	Quote string
+	// Template of the annotation of dependent brews, executed with the
+	// Dependents of the brew.
+	Annotation string
+
+	patterns    []annotationPattern
+	annotations *regexp.Regexp
+}
+
+// Dependents are the packages a brew is a dependency of, by dependency type.
+type Dependents struct {
+	RequiredBy, RecommendedFor, OptionalFor, BuildOf []string
+}
+
+// Reports whether the dependents list the same packages for every dependency
+// type, in any order.
+func (d Dependents) Equal(other Dependents) bool {
+	return sameNames(d.RequiredBy, other.RequiredBy) &&
+		sameNames(d.RecommendedFor, other.RecommendedFor) &&
+		sameNames(d.OptionalFor, other.OptionalFor) &&
+		sameNames(d.BuildOf, other.BuildOf)
+}
+
+// Reports whether there are no dependents of any type.
+func (d Dependents) IsEmpty() bool {
+	return len(d.RequiredBy)+len(d.RecommendedFor)+len(d.OptionalFor)+len(d.BuildOf) < 1
+}
+
+// Returns the list of dependents of a dependency type by the name of its field.
+func (d *Dependents) field(name string) *[]string {
+	switch name {
+	case "RequiredBy":
+		return &d.RequiredBy
+	case "RecommendedFor":
+		return &d.RecommendedFor
+	case "OptionalFor":
+		return &d.OptionalFor
+	}
+
+	return &d.BuildOf
+}
+
+// An annotationPattern matches the annotation of a single dependency type, with
+// the dependents in its first group, separated by sep.
+type annotationPattern struct {
+	field  string
+	sep    string
+	source string
+	regexp *regexp.Regexp
+}
+
+// Returns the layout bfm writes Brewfiles with unless configured otherwise.
+func DefaultLayout() *Layout {
+	return &Layout{
+		Sections:   append([]string{}, Sections...),
+		Headers:    map[string]string{},
+		BlankLines: 1,
+		Quote:      "single",
+		Annotation: DefaultAnnotation,
+	}
+}
+
+// Checks the layout and completes the section order, reporting unknown
+// sections, quote styles and annotation templates which cannot be read back.
+func (l *Layout) Validate() error {
+	var sections []string
+	for _, s := range l.Sections {
+		if !isSection(s) {
+			return ErrUnknownSection(s)
+		}
+
+		if !Contains(sections, s) {
+			sections = append(sections, s)
+		}
+	}
+
+	for _, s := range Sections {
+		if !Contains(sections, s) {
+			sections = append(sections, s)
+		}
+	}
+
+	l.Sections = sections
+
+	for s := range l.Headers {
+		if !isSection(s) {
+			return ErrUnknownSection(s)
+		}
+	}
+
+	if l.Quote != "single" && l.Quote != "double" {
+		return ErrInvalidQuoteStyle(l.Quote)
+	}
+
+	if l.BlankLines < 0 {
+		return ErrInvalidBlankLines(l.BlankLines)
+	}
+
+	patterns, annotations, err := annotationRegexp(l.Annotation)
+	if err != nil {
+		return err
+	}
+
+	l.patterns, l.annotations = patterns, annotations
+	return nil
+}
+
+// Creates the annotation of a brew with the given dependents.
+func (l *Layout) Annotate(d Dependents) (string, error) {
+	tmpl, err := parseAnnotation(l.Annotation)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, d); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(b.String()), nil
+}
+
+// Splits the comment of an entry into the annotations generated by bfm, in the
+// configured or the default style, and the free-form text written by the user.
+func (l *Layout) SplitComment(comment string) (annotation, user string) {
+	_, annotations := l.compiled()
+
+	annotation = strings.Join(annotations.FindAllString(comment, -1), " ")
+	user = strings.TrimSpace(annotations.ReplaceAllString(comment, ""))
+	user = strings.Join(strings.Fields(user), " ")
+
+	return annotation, user
+}
+
+// Parses the annotations generated by bfm, in the configured or the default style,
+// back into the dependents they list.
+func (l *Layout) ParseAnnotation(annotation string) Dependents {
+	patterns, annotations := l.compiled()
+
+	var d Dependents
+	for _, match := range annotations.FindAllString(annotation, -1) {
+		for _, p := range patterns {
+			groups := p.regexp.FindStringSubmatch(match)
+			if groups == nil {
+				continue
+			}
+
+			names := d.field(p.field)
+			for _, name := range strings.Split(groups[1], p.sep) {
+				if !Contains(*names, name) {
+					*names = append(*names, name)
+				}
+			}
+
+			break
+		}
+	}
+
+	return d
+}
+
+// Returns the patterns of the annotations of the layout and the regular
+// expression matching any of them, falling back to the default annotations if
+// the layout has not been validated and its template cannot be read back.
+func (l *Layout) compiled() ([]annotationPattern, *regexp.Regexp) {
+	if l.annotations != nil {
+		return l.patterns, l.annotations
+	}
+
+	patterns, annotations, err := annotationRegexp(l.Annotation)
+	if err != nil {
+		return defaultPatterns, defaultAnnotations
+	}
+
+	return patterns, annotations
+}
+
+// Formats an entry as a Brewfile line with the configured quotes.
+func (l *Layout) Format(e Entry) string {
+	call := e.call()
+	if l.Quote == "double" {
+		for i, a := range call.Args {
+			call.Args[i] = requote(a)
+		}
+
+		call.Options = requoteOptions(call.Options)
+	}
+
+	if e.Disabled {
+		return "# " + call.String()
+	}
+
+	return call.String()
+}
+
+// Returns the comment lines of the header of a section, or nothing if the
+// section has no header.
+func (l *Layout) header(section string) []string {
+	text := strings.TrimSpace(l.Headers[section])
+	if len(text) < 1 {
+		return nil
+	}
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		lines = append(lines, strings.TrimRight("# "+strings.TrimSpace(line), " "))
+	}
+
+	return lines
+}
+
+// Returns the comment lines without the lines of section headers, which are
+// written anew every time the Brewfile is written.
+func (l *Layout) withoutHeaders(lines []string) []string {
+	var headers []string
+	for s := range l.Headers {
+		headers = append(headers, l.header(s)...)
+	}
+
+	if len(headers) < 1 {
+		return lines
+	}
+
+	var remaining []string
+	for _, line := range lines {
+		if !Contains(headers, line) {
+			remaining = append(remaining, line)
+		}
+	}
+
+	return remaining
+}
+
+// Returns the separator between sections.
+func (l *Layout) separator() string {
+	return strings.Repeat("\n", l.BlankLines)
+}
+
+var defaultPatterns, defaultAnnotations, _ = annotationRegexp(DefaultAnnotation)
+
+func parseAnnotation(source string) (*template.Template, error) {
+	funcMap := template.FuncMap{"StringsJoin": strings.Join}
+	tmpl, err := template.New("annotation").Funcs(funcMap).Parse(source)
+	if err != nil {
+		return nil, ErrInvalidAnnotation(err.Error())
+	}
+
+	return tmpl, nil
+}
+
+// Returns the patterns of the annotations created by the given template, as well
+// as those created by the default template, and a regular expression matching
+// any of them.
+func annotationRegexp(source string) ([]annotationPattern, *regexp.Regexp, error) {
+	patterns, err := annotationPatterns(source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if source != DefaultAnnotation {
+		defaults, _ := annotationPatterns(DefaultAnnotation)
+		patterns = append(patterns, defaults...)
+	}
+
+	sources := make([]string, len(patterns))
+	for i, p := range patterns {
+		sources[i] = p.source
+	}
+
+	annotations, err := regexp.Compile(strings.Join(sources, "|"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return patterns, annotations, nil
+}
+
+// Returns the patterns of the annotations created by a template. The template is
+// executed for every dependency type with two placeholder names, to find the
+// text around the names and between them.
+func annotationPatterns(source string) ([]annotationPattern, error) {
+	tmpl, err := parseAnnotation(source)
+	if err != nil {
+		return nil, err
+	}
+
+	const first, second = "bfmfirstdependent", "bfmseconddependent"
+	names := []string{first, second}
+
+	var patterns []annotationPattern
+	for _, field := range []string{"RequiredBy", "RecommendedFor", "OptionalFor", "BuildOf"} {
+		var d Dependents
+		*d.field(field) = names
+
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, d); err != nil {
+			return nil, ErrInvalidAnnotation(err.Error())
+		}
+
+		out := strings.TrimSpace(b.String())
+		if len(out) < 1 {
+			continue
+		}
+
+		i, j := strings.Index(out, first), strings.Index(out, second)
+		if i < 0 || j <= i+len(first) || strings.Count(out, first) > 1 || strings.Count(out, second) > 1 {
+			return nil, ErrInvalidAnnotation("every dependency type must list each of its dependents once, with a separator between them")
+		}
+
+		prefix, sep, suffix := out[:i], out[i+len(first):j], out[j+len(second):]
+		if len(strings.TrimSpace(prefix)) < 1 && len(strings.TrimSpace(suffix)) < 1 {
+			return nil, ErrInvalidAnnotation("the dependents must be surrounded by some text to be read back")
+		}
+
+		pattern := regexp.QuoteMeta(prefix) + "(" + annotatedName + "(?:" + regexp.QuoteMeta(sep) + annotatedName + ")*)" + regexp.QuoteMeta(suffix)
+		for _, p := range patterns {
+			if p.source == pattern {
+				return nil, ErrInvalidAnnotation("every dependency type must be written differently to be read back")
+			}
+		}
+
+		patterns = append(patterns, annotationPattern{
+			field:  field,
+			sep:    sep,
+			source: pattern,
+			regexp: regexp.MustCompile("^" + pattern + "$"),
+		})
+	}
+
+	if len(patterns) < 1 {
+		return nil, ErrInvalidAnnotation("no dependents are written")
+	}
+
+	return patterns, nil
+}
+
+// Returns a copy of a value with its strings double quoted.
+func requote(v Value) Value {
+	switch v := v.(type) {
+	case *StringLit:
+		if v.Interpolated {
+			return v
+		}
+
+		return &Expr{Position: v.Position, Source: DoubleQuote(v.Value)}
+	case *ArrayLit:
+		array := &ArrayLit{Position: v.Position}
+		for _, e := range v.Elements {
+			array.Elements = append(array.Elements, requote(e))
+		}
+		return array
+	case *HashLit:
+		return &HashLit{Position: v.Position, Entries: requoteOptions(v.Entries)}
+	}
+
+	return v
+}
+
+func requoteOptions(options []Option) []Option {
+	requoted := make([]Option, len(options))
+	for i, o := range options {
+		requoted[i] = Option{Position: o.Position, Key: o.Key, Value: requote(o.Value)}
+	}
+
+	return requoted
+}
+
+func isSection(name string) bool {
+	return Contains(Sections, name)
+}
+
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, name := range a {
+		if !Contains(b, name) {
+			return false
+		}
+	}
+
+	return true
+}
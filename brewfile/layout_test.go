@@ -0,0 +1,155 @@
+package brewfile_test
+
+import (
+	. "github.com/LGUG2Z/bfm/brewfile"
+
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Layout", func() {
+	var layout *Layout
+
+	BeforeEach(func() {
+		layout = DefaultLayout()
+	})
+
+	It("Writes annotations in the default style", func() {
+		Expect(layout.Validate()).To(Succeed())
+
+		annotation, err := layout.Annotate(Dependents{RequiredBy: []string{"neovim", "vim"}, BuildOf: []string{"vim"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(annotation).To(Equal("[required by: neovim, vim] [build for: vim]"))
+	})
+
+	It("Reads back annotations written with a configured template, as well as in the default style", func() {
+		layout.Annotation = `{{ if .RequiredBy }}(needed by {{ StringsJoin .RequiredBy " & " }}){{ end }}{{ if .BuildOf }} (builds {{ StringsJoin .BuildOf " & " }}){{ end }}`
+		Expect(layout.Validate()).To(Succeed())
+
+		annotation, err := layout.Annotate(Dependents{RequiredBy: []string{"neovim", "vim"}, BuildOf: []string{"vim"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(annotation).To(Equal("(needed by neovim & vim) (builds vim)"))
+
+		annotation, user := layout.SplitComment("(needed by neovim & vim) (builds vim) pinned by ops")
+		Expect(annotation).To(Equal("(needed by neovim & vim) (builds vim)"))
+		Expect(user).To(Equal("pinned by ops"))
+
+		annotation, user = layout.SplitComment("[required by: jq] pinned by ops")
+		Expect(annotation).To(Equal("[required by: jq]"))
+		Expect(user).To(Equal("pinned by ops"))
+	})
+
+	It("Parses annotations back into the dependents they list", func() {
+		layout.Annotation = `{{ if .RequiredBy }}(needed by {{ StringsJoin .RequiredBy " & " }}){{ end }}{{ if .BuildOf }} (builds {{ StringsJoin .BuildOf " & " }}){{ end }}`
+		Expect(layout.Validate()).To(Succeed())
+
+		d := layout.ParseAnnotation("(needed by neovim & homebrew/core/vim) [optional for: weechat] (builds vim)")
+		Expect(d.RequiredBy).To(Equal([]string{"neovim", "homebrew/core/vim"}))
+		Expect(d.OptionalFor).To(Equal([]string{"weechat"}))
+		Expect(d.BuildOf).To(Equal([]string{"vim"}))
+		Expect(d.Equal(Dependents{RequiredBy: []string{"homebrew/core/vim", "neovim"}, OptionalFor: []string{"weechat"}, BuildOf: []string{"vim"}})).To(BeTrue())
+
+		Expect(layout.ParseAnnotation("needed by neovim").IsEmpty()).To(BeTrue())
+	})
+
+	It("Rejects annotation templates which cannot be read back", func() {
+		layout.Annotation = `{{ StringsJoin .RequiredBy ", " }}`
+		Expect(layout.Validate()).To(HaveOccurred())
+
+		layout.Annotation = `{{ if .RequiredBy }}[required by: {{ index .RequiredBy 0 }}]{{ end }}`
+		Expect(layout.Validate()).To(HaveOccurred())
+
+		layout.Annotation = `{{ if .RequiredBy }`
+		Expect(layout.Validate()).To(HaveOccurred())
+	})
+
+	It("Completes the section order and rejects unknown sections and quote styles", func() {
+		layout.Sections = []string{"cask", "tap"}
+		Expect(layout.Validate()).To(Succeed())
+		Expect(layout.Sections).To(Equal([]string{"cask", "tap", "brew", "dependent", "mas", "whalebrew", "vscode", "include"}))
+
+		layout.Sections = []string{"casks"}
+		Expect(layout.Validate()).To(Equal(ErrUnknownSection("casks")))
+
+		layout.Sections, layout.Quote = nil, "backtick"
+		Expect(layout.Validate()).To(Equal(ErrInvalidQuoteStyle("backtick")))
+	})
+
+	It("Formats entries with double quotes", func() {
+		layout.Quote = "double"
+		Expect(layout.Validate()).To(Succeed())
+
+		file, err := Parse("Brewfile", []byte(`brew 'vim', args: ['HEAD'], postinstall: '"#{HOMEBREW_PREFIX}/bin/vim"' # editor`))
+		Expect(err).ToNot(HaveOccurred())
+
+		entry, ok := NewEntry(file.Nodes[0].(*Call))
+		Expect(ok).To(BeTrue())
+		Expect(layout.Format(entry)).To(Equal(`brew "vim", args: ["HEAD"], postinstall: "\"\#{HOMEBREW_PREFIX}/bin/vim\"" # editor`))
+	})
+
+	Describe("When writing a Brewfile", func() {
+		var (
+			bf       = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "/src/github.com/LGUG2Z/bfm/testData/testBrewfile")
+			contents = `tap 'homebrew/bundle'
+cask 'firefox'
+brew 'jq'
+brew 'oniguruma' # [required by: jq]
+`
+		)
+
+		BeforeEach(func() {
+			ioutil.WriteFile(bf, []byte(contents), 0644)
+
+			layout.Sections = []string{"cask", "brew", "dependent"}
+			layout.Headers = map[string]string{"cask": "Apps", "brew": "Tools"}
+			layout.BlankLines = 2
+			layout.Quote = "double"
+			Expect(layout.Validate()).To(Succeed())
+		})
+
+		AfterEach(func() {
+			os.Remove(bf)
+		})
+
+		It("Writes the sections in the configured order with their headers, blank lines and quotes", func() {
+			packages := Packages{Layout: layout}
+			Expect(packages.FromBrewfile(bf)).To(Succeed())
+
+			actual, err := packages.Bytes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(actual)).To(Equal(`# Apps
+cask "firefox"
+
+
+# Tools
+brew "jq"
+
+
+brew "oniguruma" # [required by: jq]
+
+
+tap "homebrew/bundle"
+`))
+		})
+
+		It("Does not repeat the headers when the Brewfile is rewritten with its comments", func() {
+			packages := Packages{Layout: layout, KeepComments: true}
+			Expect(packages.FromBrewfile(bf)).To(Succeed())
+
+			first, err := packages.Bytes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ioutil.WriteFile(bf, first, 0644)).To(Succeed())
+
+			Expect(packages.FromBrewfile(bf)).To(Succeed())
+			Expect(packages.Cask[0].Doc).To(BeEmpty())
+
+			second, err := packages.Bytes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(second)).To(Equal(string(first)))
+		})
+	})
+})
@@ -0,0 +1,359 @@
+package brewfile
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/LGUG2Z/bfm/diagnostics"
+)
+
+type TokenKind int
+
+const (
+	EOF TokenKind = iota
+	Newline
+	Comment
+	Ident
+	Label
+	String
+	Symbol
+	Number
+	Comma
+	Dot
+	Colon2
+	Arrow
+	Operator
+	LParen
+	RParen
+	LBracket
+	RBracket
+	LBrace
+	RBrace
+	Pipe
+	Semicolon
+	Illegal
+)
+
+var tokenNames = map[TokenKind]string{
+	EOF:       "end of file",
+	Newline:   "newline",
+	Comment:   "comment",
+	Ident:     "identifier",
+	Label:     "label",
+	String:    "string",
+	Symbol:    "symbol",
+	Number:    "number",
+	Comma:     "','",
+	Dot:       "'.'",
+	Colon2:    "'::'",
+	Arrow:     "'=>'",
+	Operator:  "operator",
+	LParen:    "'('",
+	RParen:    "')'",
+	LBracket:  "'['",
+	RBracket:  "']'",
+	LBrace:    "'{'",
+	RBrace:    "'}'",
+	Pipe:      "'|'",
+	Semicolon: "';'",
+	Illegal:   "illegal character",
+}
+
+func (k TokenKind) String() string {
+	if name, ok := tokenNames[k]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("token(%d)", int(k))
+}
+
+// A Token is a single lexical element of a Brewfile. Text is the raw source of
+// the token and Value its decoded form (the contents of a string without quotes
+// and escapes, the name of a symbol or label, the text of a comment). A double
+// quoted string containing #{...} is Interpolated.
+type Token struct {
+	Kind         TokenKind
+	Text, Value  string
+	Quote        byte
+	Interpolated bool
+	Pos          Pos
+	Offset, End  int
+}
+
+// Lexer splits the Ruby DSL subset accepted by Homebrew Bundle into tokens.
+type Lexer struct {
+	src      string
+	filename string
+	offset   int
+	line     int
+	column   int
+}
+
+func NewLexer(filename, src string) *Lexer {
+	return &Lexer{src: src, filename: filename, line: 1, column: 1}
+}
+
+// Tokenizes the whole source, always terminating the result with an EOF token.
+// Malformed input is kept as Illegal tokens and reported together once the
+// whole source has been read.
+func (l *Lexer) Tokens() ([]Token, error) {
+	var tokens []Token
+	var errs diagnostics.List
+
+	for {
+		t, err := l.Next()
+		if err != nil {
+			errs.Add(t.Pos, err)
+		}
+
+		tokens = append(tokens, t)
+
+		if t.Kind == EOF {
+			return tokens, errs.Err()
+		}
+	}
+}
+
+// Returns the next token in the source.
+func (l *Lexer) Next() (Token, error) {
+	l.skipSpace()
+
+	start, pos := l.offset, l.pos()
+
+	if l.offset >= len(l.src) {
+		return l.token(EOF, start, pos, ""), nil
+	}
+
+	r := l.peek()
+
+	switch {
+	case r == '\n':
+		l.advance()
+		return l.token(Newline, start, pos, "\n"), nil
+	case r == '#':
+		for l.offset < len(l.src) && l.peek() != '\n' {
+			l.advance()
+		}
+		return l.token(Comment, start, pos, l.src[start+1:l.offset]), nil
+	case r == '\'' || r == '"':
+		return l.lexString(start, pos)
+	case r == ':':
+		return l.lexColon(start, pos)
+	case isIdentStart(r):
+		return l.lexIdent(start, pos), nil
+	case unicode.IsDigit(r) || (r == '-' && unicode.IsDigit(l.peekAt(1))):
+		l.advance()
+		for l.offset < len(l.src) && (unicode.IsDigit(l.peek()) || l.peek() == '.' || l.peek() == '_') {
+			l.advance()
+		}
+		return l.token(Number, start, pos, l.src[start:l.offset]), nil
+	}
+
+	l.advance()
+
+	switch r {
+	case ',':
+		return l.token(Comma, start, pos, ","), nil
+	case '.':
+		return l.token(Dot, start, pos, "."), nil
+	case '(':
+		return l.token(LParen, start, pos, "("), nil
+	case ')':
+		return l.token(RParen, start, pos, ")"), nil
+	case '[':
+		return l.token(LBracket, start, pos, "["), nil
+	case ']':
+		return l.token(RBracket, start, pos, "]"), nil
+	case '{':
+		return l.token(LBrace, start, pos, "{"), nil
+	case '}':
+		return l.token(RBrace, start, pos, "}"), nil
+	case ';':
+		return l.token(Semicolon, start, pos, ";"), nil
+	case '=':
+		switch l.peek() {
+		case '>':
+			l.advance()
+			return l.token(Arrow, start, pos, "=>"), nil
+		case '=', '~':
+			l.advance()
+		}
+		return l.token(Operator, start, pos, l.src[start:l.offset]), nil
+	case '!':
+		if l.peek() == '=' || l.peek() == '~' {
+			l.advance()
+		}
+		return l.token(Operator, start, pos, l.src[start:l.offset]), nil
+	case '&':
+		if l.peek() == '&' {
+			l.advance()
+			return l.token(Operator, start, pos, "&&"), nil
+		}
+	case '|':
+		if l.peek() == '|' {
+			l.advance()
+			return l.token(Operator, start, pos, "||"), nil
+		}
+		return l.token(Pipe, start, pos, "|"), nil
+	case '<', '>':
+		if l.peek() == '=' {
+			l.advance()
+		}
+		return l.token(Operator, start, pos, l.src[start:l.offset]), nil
+	}
+
+	return l.token(Illegal, start, pos, l.src[start:l.offset]), diagnostics.Errorf(pos, "unexpected character %q", r)
+}
+
+func (l *Lexer) lexString(start int, pos Pos) (Token, error) {
+	quote := l.advance()
+	interpolated := false
+
+	var value strings.Builder
+	for {
+		if l.offset >= len(l.src) {
+			return l.token(Illegal, start, pos, ""), diagnostics.Errorf(pos, "unterminated string")
+		}
+
+		r := l.advance()
+
+		if r == rune(quote) {
+			break
+		}
+
+		if r == '\\' && l.offset < len(l.src) {
+			escaped := l.advance()
+
+			switch {
+			case quote == '\'' && escaped != '\'' && escaped != '\\':
+				value.WriteRune(r)
+				value.WriteRune(escaped)
+			case quote == '"' && escaped == 'n':
+				value.WriteRune('\n')
+			case quote == '"' && escaped == 't':
+				value.WriteRune('\t')
+			default:
+				value.WriteRune(escaped)
+			}
+
+			continue
+		}
+
+		if quote == '"' && r == '#' && l.offset < len(l.src) && l.src[l.offset] == '{' {
+			interpolated = true
+		}
+
+		value.WriteRune(r)
+	}
+
+	t := l.token(String, start, pos, value.String())
+	t.Quote, t.Interpolated = byte(quote), interpolated
+	return t, nil
+}
+
+func (l *Lexer) lexColon(start int, pos Pos) (Token, error) {
+	l.advance()
+
+	switch {
+	case l.peek() == ':':
+		l.advance()
+		return l.token(Colon2, start, pos, "::"), nil
+	case l.peek() == '"' || l.peek() == '\'':
+		t, err := l.lexString(l.offset, l.pos())
+		if err != nil {
+			return t, err
+		}
+		return l.token(Symbol, start, pos, t.Value), nil
+	case isIdentStart(l.peek()):
+		ident := l.lexIdent(l.offset, l.pos())
+		return l.token(Symbol, start, pos, ident.Value), nil
+	}
+
+	return l.token(Illegal, start, pos, ":"), diagnostics.Errorf(pos, "unexpected ':'")
+}
+
+func (l *Lexer) lexIdent(start int, pos Pos) Token {
+	for l.offset < len(l.src) && isIdentPart(l.peek()) {
+		l.advance()
+	}
+
+	if l.offset < len(l.src) && (l.peek() == '?' || l.peek() == '!') && l.peekAt(1) != '=' {
+		l.advance()
+	}
+
+	name := l.src[start:l.offset]
+
+	// A label is an identifier immediately followed by a single colon, as in
+	// the `args: [...]` options of an entry.
+	if l.peek() == ':' && l.peekAt(1) != ':' {
+		l.advance()
+		return l.token(Label, start, pos, name)
+	}
+
+	return l.token(Ident, start, pos, name)
+}
+
+func (l *Lexer) skipSpace() {
+	for l.offset < len(l.src) {
+		switch r := l.peek(); {
+		case r == ' ' || r == '\t' || r == '\r':
+			l.advance()
+		case r == '\\' && l.peekAt(1) == '\n':
+			l.advance()
+			l.advance()
+		default:
+			return
+		}
+	}
+}
+
+func (l *Lexer) token(kind TokenKind, start int, pos Pos, value string) Token {
+	return Token{Kind: kind, Text: l.src[start:l.offset], Value: value, Pos: pos, Offset: start, End: l.offset}
+}
+
+func (l *Lexer) pos() Pos {
+	return Pos{Filename: l.filename, Line: l.line, Column: l.column}
+}
+
+func (l *Lexer) peek() rune {
+	return l.peekAt(0)
+}
+
+func (l *Lexer) peekAt(n int) rune {
+	offset := l.offset
+	for i := 0; i < n && offset < len(l.src); i++ {
+		_, size := utf8.DecodeRuneInString(l.src[offset:])
+		offset += size
+	}
+
+	if offset >= len(l.src) {
+		return 0
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.src[offset:])
+	return r
+}
+
+func (l *Lexer) advance() rune {
+	r, size := utf8.DecodeRuneInString(l.src[l.offset:])
+	l.offset += size
+
+	if r == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+
+	return r
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -1,109 +1,488 @@
 package brewfile
 
 import (
-	"bytes"
-	"io/ioutil"
+	"path/filepath"
 	"sort"
 	"strings"
-	"text/template"
+
+	"github.com/LGUG2Z/bfm/diagnostics"
+	. "github.com/LGUG2Z/bfm/helpers"
 )
 
 type Packages struct {
-	Tap, Brew, Cask, Mas []string
+	// The entries of the Brewfile and of every Brewfile it includes.
+	Tap, Brew, Cask, Mas, Whalebrew, Vscode Entries
+
+	// Path of the Brewfile the packages were read from.
+	Path string
+
+	// Conditions of the blocks in the Brewfile, such as "if OS.mac?", in the
+	// order they first appear.
+	Conditions []string
+
+	// Lines of the Brewfile including other Brewfiles.
+	Includes []Include
+
+	// Lines of the Brewfile which are neither entries nor includes, such as
+	// cask_args, in the order they were written.
+	Statements []Statement
+
+	// The Brewfiles included by the Brewfile, directly or through other
+	// included Brewfiles, with their own comments, conditions and includes.
+	// Their entries are in the lists above, with File set to their path.
+	Included []*Packages
+
+	// When set before reading a Brewfile, comments are collected and written
+	// back out by Bytes instead of being discarded.
+	KeepComments bool
+	Comments     *Comments
+
+	// Layout the Brewfile is read and written with, or the default layout when
+	// not set.
+	Layout *Layout
 }
 
-// Parses a Brewfile and separates the taps, brews, casks and mas apps.
+// Parses a Brewfile and the Brewfiles it includes, and separates the taps,
+// brews, casks, mas apps, whalebrew images and VS Code extensions.
 func (p *Packages) FromBrewfile(brewfilePath string) error {
-	bytes, err := ioutil.ReadFile(brewfilePath)
+	seen := map[string]bool{filepath.Clean(brewfilePath): true}
+	return p.fromBrewfile(brewfilePath, []string{filepath.Clean(brewfilePath)}, seen)
+}
+
+// Reads a Brewfile followed by every Brewfile it includes which has not been
+// read yet. Includes of a Brewfile which is already being read are reported as
+// cycles.
+func (p *Packages) fromBrewfile(path string, stack []string, seen map[string]bool) error {
+	file, err := ParseFile(path)
 	if err != nil {
 		return err
 	}
 
-	lines := strings.Split(string(bytes), "\n")
+	p.FromFile(file)
+	p.Path = path
+
+	var errs diagnostics.List
+	for _, include := range p.Includes {
+		if Contains(stack, include.Path) {
+			errs.Add(include.Pos, ErrIncludeCycle(append(stack, include.Path)))
+			continue
+		}
+
+		if seen[include.Path] {
+			continue
+		}
+
+		seen[include.Path] = true
+
+		included := &Packages{KeepComments: p.KeepComments, Layout: p.Layout}
+		if err := included.fromBrewfile(include.Path, append(stack, include.Path), seen); err != nil {
+			errs.Add(include.Pos, err)
+			continue
+		}
+
+		p.merge(included)
+	}
+
+	return errs.Err()
+}
+
+// Moves the entries and included Brewfiles of an included Brewfile into the
+// Brewfile including it.
+func (p *Packages) merge(included *Packages) {
+	for _, entries := range []Entries{included.Tap, included.Brew, included.Cask, included.Mas, included.Whalebrew, included.Vscode} {
+		for _, e := range entries {
+			if len(e.File) < 1 {
+				e.File = included.Path
+			}
+
+			p.Add(e)
+		}
+	}
+
+	p.Included = append(append(p.Included, included), included.Included...)
+	included.Tap, included.Brew, included.Cask, included.Mas, included.Whalebrew, included.Vscode = nil, nil, nil, nil, nil, nil
+	included.Included = nil
+
+	p.Tap.Sort()
+	p.Brew.Sort()
+	p.Cask.Sort()
+	p.Mas.Sort()
+	p.Whalebrew.Sort()
+	p.Vscode.Sort()
+}
+
+// Returns the path of the Brewfile and of every Brewfile it includes.
+func (p *Packages) Files() []string {
+	files := []string{p.Path}
+	for _, included := range p.Included {
+		files = append(files, included.Path)
+	}
+
+	return files
+}
+
+// Separates the taps, brews, casks, mas apps, whalebrew images and VS Code
+// extensions of a parsed Brewfile, including those nested inside blocks.
+// Included Brewfiles are listed in Includes but not read.
+func (p *Packages) FromFile(file *File) {
+	p.Tap, p.Brew, p.Cask, p.Mas, p.Whalebrew, p.Vscode, p.Comments = nil, nil, nil, nil, nil, nil, nil
+	p.Conditions, p.Includes, p.Statements, p.Included = nil, nil, nil, nil
 
-	p.Tap = separate("tap", lines)
-	p.Brew = separate("brew", lines)
-	p.Cask = separate("cask", lines)
-	p.Mas = separate("mas", lines)
+	layout := p.layout()
 
-	sort.Strings(p.Tap)
-	sort.Strings(p.Brew)
-	sort.Strings(p.Cask)
-	sort.Strings(p.Mas)
+	comments := NewComments()
+	comments.collect(file.Nodes, filepath.Dir(file.Path), func(entry Entry, doc []string) {
+		p.addCondition(entry.Condition)
+
+		entry.Annotation, entry.Comment = layout.SplitComment(strings.TrimSpace(entry.Annotation + " " + entry.Comment))
+		doc = layout.withoutHeaders(doc)
+
+		if !p.KeepComments {
+			if entry.Disabled {
+				return
+			}
+
+			entry.Comment = ""
+		} else {
+			entry.Doc = doc
+		}
+
+		if entry.Disabled {
+			comments.Disabled[entry.Type] = append(comments.Disabled[entry.Type], entry)
+			return
+		}
+
+		p.Add(entry)
+	}, func(include Include) {
+		p.addCondition(include.Condition)
+
+		include.Doc = layout.withoutHeaders(include.Doc)
+
+		if !p.KeepComments {
+			include.Comment, include.Doc = "", nil
+		}
+
+		p.Includes = append(p.Includes, include)
+	}, func(statement Statement) {
+		p.addCondition(statement.Condition)
+
+		statement.Doc = layout.withoutHeaders(statement.Doc)
+
+		if !p.KeepComments {
+			statement.Comment, statement.Doc = "", nil
+		}
+
+		p.Statements = append(p.Statements, statement)
+	})
+
+	if p.KeepComments {
+		p.Comments = comments
+	}
+
+	p.Tap.Sort()
+	p.Brew.Sort()
+	p.Cask.Sort()
+	p.Mas.Sort()
+	p.Whalebrew.Sort()
+	p.Vscode.Sort()
+}
+
+// Returns the layout the Brewfile is read and written with.
+func (p *Packages) layout() *Layout {
+	if p.Layout == nil {
+		return DefaultLayout()
+	}
+
+	return p.Layout
+}
+
+func (p *Packages) addCondition(condition string) {
+	if len(condition) > 0 && !Contains(p.Conditions, condition) {
+		p.Conditions = append(p.Conditions, condition)
+	}
+}
+
+// Adds an entry to the list of its package type.
+func (p *Packages) Add(entry Entry) {
+	switch entry.Type {
+	case "tap":
+		p.Tap = append(p.Tap, entry)
+	case "brew":
+		p.Brew = append(p.Brew, entry)
+	case "cask":
+		p.Cask = append(p.Cask, entry)
+	case "mas":
+		p.Mas = append(p.Mas, entry)
+	case "whalebrew":
+		p.Whalebrew = append(p.Whalebrew, entry)
+	case "vscode":
+		p.Vscode = append(p.Vscode, entry)
+	}
+}
+
+// Returns the entries of the given package type.
+func (p *Packages) Entries(packageType string) Entries {
+	switch packageType {
+	case "tap":
+		return p.Tap
+	case "brew":
+		return p.Brew
+	case "cask":
+		return p.Cask
+	case "mas":
+		return p.Mas
+	case "whalebrew":
+		return p.Whalebrew
+	case "vscode":
+		return p.Vscode
+	}
 
 	return nil
 }
 
-// Creates the final output of an updated Brewfile as a byte array in the order taps ->
-// primary brews -> dependent brews -> casks -> mas apps.
+// Reports whether the Brewfile has an entry of the given package type and name.
+func (p *Packages) Contains(packageType, name string) bool {
+	return p.Entries(packageType).Contains(name)
+}
+
+// Creates the final output of an updated Brewfile as a byte array with the sections
+// in the order of the layout after any statements which are not entries, such as
+// cask_args, by default taps -> primary brews -> dependent brews ->
+// casks -> mas apps -> whalebrew images -> VS Code extensions -> includes, followed
+// by a block in the same order for each condition. In round-trip mode the header, entry and commented-out entry
+// comments are written back too. Entries of included Brewfiles are left out.
 func (p *Packages) Bytes() ([]byte, error) {
-	entries := `{{ range . }}
-{{- . }}
-{{ end -}}`
-
-	var primaryBrews []string
-	var dependentBrews []string
-	for _, b := range p.Brew {
-		if strings.Contains(b, "#") {
-			dependentBrews = append(dependentBrews, b)
-		} else {
-			primaryBrews = append(primaryBrews, b)
+	return p.FileBytes(p.Path)
+}
+
+// Creates the output of the Brewfile or included Brewfile at the given path,
+// with only the entries written in that Brewfile.
+func (p *Packages) FileBytes(path string) ([]byte, error) {
+	if path == p.Path {
+		return p.view("", p).render(), nil
+	}
+
+	for _, included := range p.Included {
+		if included.Path == path {
+			return p.view(path, included).render(), nil
 		}
 	}
 
-	var tapBuffer, primaryBuffer, dependentBuffer, caskBuffer, masBuffer bytes.Buffer
+	return nil, ErrNotIncluded(path)
+}
 
-	tmpl := template.Must(template.New("entries").Parse(entries))
-	if err := tmpl.Execute(&tapBuffer, p.Tap); err != nil {
-		return []byte{}, err
+// Returns the entries written in the given file, with the comments, conditions
+// and includes of the Brewfile read from that file.
+func (p *Packages) view(file string, source *Packages) *Packages {
+	return &Packages{
+		Tap:        p.Tap.In(file),
+		Brew:       p.Brew.In(file),
+		Cask:       p.Cask.In(file),
+		Mas:        p.Mas.In(file),
+		Whalebrew:  p.Whalebrew.In(file),
+		Vscode:     p.Vscode.In(file),
+		Path:       source.Path,
+		Conditions: source.Conditions,
+		Includes:   source.Includes,
+		Statements: source.Statements,
+		Comments:   source.Comments,
+		Layout:     p.Layout,
 	}
+}
 
-	if err := tmpl.Execute(&primaryBuffer, primaryBrews); err != nil {
-		return []byte{}, err
+func (p *Packages) render() []byte {
+	sections := p.sections("")
+
+	for _, condition := range p.conditions() {
+		if block := p.block(condition); len(block) > 0 {
+			sections = append(sections, block)
+		}
 	}
 
-	if err := tmpl.Execute(&dependentBuffer, dependentBrews); err != nil {
-		return []byte{}, err
+	if p.Comments != nil {
+		sections = append(sections, comment(p.Comments.Footer))
 	}
 
-	if err := tmpl.Execute(&caskBuffer, p.Cask); err != nil {
-		return []byte{}, err
+	out := join(sections, p.layout().separator())
+
+	// The header is always set apart by a blank line, to be read back as the
+	// header rather than as the comment of the first entry.
+	if p.Comments != nil && len(p.Comments.Header) > 0 {
+		out = join([]string{comment(p.Comments.Header), out}, "\n")
 	}
 
-	if err := tmpl.Execute(&masBuffer, p.Mas); err != nil {
-		return []byte{}, err
+	return []byte(out)
+}
+
+// Joins the non-empty sections with the given separator.
+func join(sections []string, separator string) string {
+	var nonEmpty []string
+	for _, s := range sections {
+		if len(s) > 0 {
+			nonEmpty = append(nonEmpty, s)
+		}
 	}
 
-	var lines []string
-	if len(tapBuffer.String()) > 0 {
-		lines = append(lines, tapBuffer.String())
+	return strings.Join(nonEmpty, separator)
+}
+
+// Returns the conditions of the Brewfile in the order they first appeared,
+// followed by the conditions of newly added entries in alphabetical order.
+func (p *Packages) conditions() []string {
+	conditions := append([]string{}, p.Conditions...)
+
+	var added []string
+	for _, entries := range []Entries{p.Tap, p.Brew, p.Cask, p.Mas, p.Whalebrew, p.Vscode} {
+		for _, e := range entries {
+			if len(e.Condition) > 0 && !Contains(conditions, e.Condition) && !Contains(added, e.Condition) {
+				added = append(added, e.Condition)
+			}
+		}
 	}
 
-	if len(primaryBuffer.String()) > 0 {
-		lines = append(lines, primaryBuffer.String())
+	sort.Strings(added)
+	return append(conditions, added...)
+}
+
+// Returns the formatted sections of the entries with the given condition in the
+// order of the layout, each under its header if it has one.
+func (p *Packages) sections(condition string) []string {
+	var primaryBrews, dependentBrews Entries
+	for _, b := range p.Brew.When(condition) {
+		if len(b.Annotation) > 0 && !b.Explicit {
+			dependentBrews = append(dependentBrews, b)
+		} else {
+			primaryBrews = append(primaryBrews, b)
+		}
 	}
-	if len(dependentBuffer.String()) > 0 {
-		lines = append(lines, dependentBuffer.String())
+
+	layout := p.layout()
+
+	sections := []string{p.statements(condition)}
+	for _, name := range layout.Sections {
+		var s string
+		switch name {
+		case "brew":
+			s = p.section("brew", condition, primaryBrews)
+		case "dependent":
+			s = p.section("", condition, dependentBrews)
+		case "include":
+			s = p.includes(condition)
+		default:
+			s = p.section(name, condition, p.Entries(name).When(condition))
+		}
+
+		if len(s) > 0 {
+			s = comment(layout.header(name)) + s
+		}
+
+		sections = append(sections, s)
 	}
 
-	if len(caskBuffer.String()) > 0 {
-		lines = append(lines, caskBuffer.String())
+	return sections
+}
+
+// Formats the includes with the given condition in the order they were written.
+func (p *Packages) includes(condition string) string {
+	var b strings.Builder
+	for _, i := range p.Includes {
+		if i.Condition != condition {
+			continue
+		}
+
+		if len(i.Doc) > 0 && b.Len() > 0 {
+			b.WriteString("\n")
+		}
+
+		b.WriteString(comment(i.Doc))
+		b.WriteString(i.String())
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// Formats the statements with the given condition in the order they were
+// written, ahead of the entries they may apply to.
+func (p *Packages) statements(condition string) string {
+	var b strings.Builder
+	for _, s := range p.Statements {
+		if s.Condition != condition {
+			continue
+		}
+
+		if len(s.Doc) > 0 && b.Len() > 0 {
+			b.WriteString("\n")
+		}
+
+		b.WriteString(comment(s.Doc))
+		b.WriteString(s.String())
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// Formats the entries with the given condition as an indented block, separating
+// the sections with blank lines.
+func (p *Packages) block(condition string) string {
+	sections := join(p.sections(condition), p.layout().separator())
+	if len(sections) < 1 {
+		return ""
 	}
 
-	if len(masBuffer.String()) > 0 {
-		lines = append(lines, masBuffer.String())
+	var b strings.Builder
+	b.WriteString(condition + "\n")
+
+	for _, line := range strings.SplitAfter(sections, "\n") {
+		if len(strings.TrimSpace(line)) > 0 {
+			b.WriteString("  ")
+		}
+
+		b.WriteString(line)
 	}
 
-	return []byte(strings.Join(lines, "\n")), nil
+	b.WriteString("end\n")
+	return b.String()
 }
 
-func separate(packageType string, lines []string) []string {
-	var packages []string
-	for _, line := range lines {
-		if strings.HasPrefix(line, packageType) {
-			packages = append(packages, line)
+// Formats the entries of a section along with the commented-out entries of the
+// given package type and condition, sorted in among them. Entries with comment
+// lines above them are set apart by a blank line.
+func (p *Packages) section(packageType, condition string, entries Entries) string {
+	if p.Comments != nil && len(p.Comments.Disabled[packageType].When(condition)) > 0 {
+		entries = append(Entries{}, entries...)
+
+		for _, d := range p.Comments.Disabled[packageType].When(condition) {
+			if !entries.Contains(d.Name) {
+				entries = append(entries, d)
+			}
+		}
+
+		entries.Sort()
+	}
+
+	var b strings.Builder
+	for i, e := range entries {
+		if len(e.Doc) > 0 {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+
+			b.WriteString(comment(e.Doc))
 		}
+
+		b.WriteString(p.layout().Format(e))
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func comment(lines []string) string {
+	if len(lines) < 1 {
+		return ""
 	}
 
-	return packages
+	return strings.Join(lines, "\n") + "\n"
 }
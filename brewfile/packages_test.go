@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -24,6 +25,15 @@ cask 'google-chrome'
 mas 'Xcode', id: 497799835
 cask 'firefox'
 # some comment
+brewery 'ale'
+  brew "vim", args: [
+    'HEAD'
+  ]
+if OS.mac?
+  cask 'macvim'
+end
+vscode 'golang.go'
+whalebrew 'whalebrew/wget'
 `
 		)
 
@@ -36,16 +46,31 @@ cask 'firefox'
 		})
 
 		It("Reads, separates and stores packages from the Brewfile", func() {
-			bf := fmt.Sprintf(bf)
-			packages.FromBrewfile(bf)
-			expectedPackages := Packages{
-				Tap:  []string{"tap 'homebrew/bundle'", "tap 'homebrew/core'"},
-				Brew: []string{"brew 'a2ps'"},
-				Cask: []string{"cask 'firefox'", "cask 'google-chrome'"},
-				Mas:  []string{"mas 'Xcode', id: 497799835"},
-			}
+			Expect(packages.FromBrewfile(bf)).To(Succeed())
 
-			Expect(packages).To(Equal(expectedPackages))
+			Expect(packages.Tap.Lines()).To(Equal([]string{"tap 'homebrew/bundle'", "tap 'homebrew/core'"}))
+			Expect(packages.Brew.Lines()).To(Equal([]string{"brew 'a2ps'", "brew 'vim', args: ['HEAD']"}))
+			Expect(packages.Cask.Lines()).To(Equal([]string{"cask 'firefox'", "cask 'google-chrome'", "cask 'macvim'"}))
+			Expect(packages.Mas.Lines()).To(Equal([]string{"mas 'Xcode', id: 497799835"}))
+			Expect(packages.Whalebrew.Lines()).To(Equal([]string{"whalebrew 'whalebrew/wget'"}))
+			Expect(packages.Vscode.Lines()).To(Equal([]string{"vscode 'golang.go'"}))
+		})
+
+		It("Stores every entry with its type, name, options and position", func() {
+			Expect(packages.FromBrewfile(bf)).To(Succeed())
+
+			vim, ok := packages.Brew.Find("vim")
+			Expect(ok).To(BeTrue())
+			Expect(vim.Type).To(Equal("brew"))
+			Expect(vim.Options.Keys()).To(Equal([]string{"args"}))
+			Expect(vim.Pos.Line).To(Equal(10))
+
+			xcode, ok := packages.Mas.Find("Xcode")
+			Expect(ok).To(BeTrue())
+
+			id, ok := xcode.Options.Get("id")
+			Expect(ok).To(BeTrue())
+			Expect(id.String()).To(Equal("497799835"))
 		})
 	})
 
@@ -53,10 +78,12 @@ cask 'firefox'
 		It("Produces a byte representation of the contents to be written to disk", func() {
 
 			packages := Packages{
-				Tap:  []string{"tap 'homebrew/bundle'", "tap 'homebrew/core'"},
-				Brew: []string{"brew 'a2ps'"},
-				Cask: []string{"cask 'firefox'", "cask 'google-chrome'"},
-				Mas:  []string{"mas 'Xcode', id: 497799835"},
+				Tap:       Entries{{Type: "tap", Name: "homebrew/bundle"}, {Type: "tap", Name: "homebrew/core"}},
+				Brew:      Entries{{Type: "brew", Name: "a2ps"}},
+				Cask:      Entries{{Type: "cask", Name: "firefox"}, {Type: "cask", Name: "google-chrome"}},
+				Mas:       Entries{{Type: "mas", Name: "Xcode", Options: Options{}.Set("id", &NumberLit{Text: "497799835"})}},
+				Whalebrew: Entries{{Type: "whalebrew", Name: "whalebrew/wget"}},
+				Vscode:    Entries{{Type: "vscode", Name: "golang.go"}},
 			}
 
 			actual, err := packages.Bytes()
@@ -70,9 +97,319 @@ cask 'firefox'
 cask 'google-chrome'
 
 mas 'Xcode', id: 497799835
+
+whalebrew 'whalebrew/wget'
+
+vscode 'golang.go'
 `)
 			Expect(actual).To(Equal(expected))
 		})
 	})
 
+	Describe("When reading a Brewfile with conditional blocks", func() {
+		var (
+			bf       = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "/src/github.com/LGUG2Z/bfm/testData/testBrewfile")
+			contents = `brew 'git'
+if OS.mac?
+  cask 'firefox'
+  mas 'Xcode', id: 497799835
+elsif OS.linux?
+  brew 'xclip'
+else
+  brew 'jq'
+end
+unless ENV['CI']
+  cask 'iterm2'
+end
+brew 'htop' if OS.linux?
+`
+		)
+
+		BeforeEach(func() {
+			ioutil.WriteFile(bf, []byte(contents), 0644)
+		})
+
+		AfterEach(func() {
+			os.Remove(bf)
+		})
+
+		It("Stores the condition of the block of every entry", func() {
+			packages := Packages{}
+			Expect(packages.FromBrewfile(bf)).To(Succeed())
+
+			git, _ := packages.Brew.Find("git")
+			xclip, _ := packages.Brew.Find("xclip")
+			jq, _ := packages.Brew.Find("jq")
+			htop, _ := packages.Brew.Find("htop")
+
+			Expect(git.Condition).To(Equal(""))
+			Expect(packages.Cask[0].Condition).To(Equal("if OS.mac?"))
+			Expect(packages.Mas[0].Condition).To(Equal("if OS.mac?"))
+			Expect(xclip.Condition).To(Equal("if !OS.mac? && OS.linux?"))
+			Expect(jq.Condition).To(Equal("if !OS.mac? && !OS.linux?"))
+			Expect(htop.Condition).To(Equal("if OS.linux?"))
+
+			Expect(packages.Conditions).To(Equal([]string{"if OS.mac?", "if !OS.mac? && OS.linux?", "if !OS.mac? && !OS.linux?", "unless ENV['CI']", "if OS.linux?"}))
+		})
+
+		It("Writes the entries of every condition back out in a block", func() {
+			packages := Packages{}
+			Expect(packages.FromBrewfile(bf)).To(Succeed())
+			packages.Cask = append(packages.Cask, Entry{Type: "cask", Name: "docker", Condition: "if OS.mac?"})
+			packages.Cask.Sort()
+			packages.Vscode = append(packages.Vscode, Entry{Type: "vscode", Name: "golang.go", Condition: "if ENV['WORK']"})
+
+			actual, err := packages.Bytes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(actual)).To(Equal(`brew 'git'
+
+if OS.mac?
+  cask 'docker'
+  cask 'firefox'
+
+  mas 'Xcode', id: 497799835
+end
+
+if !OS.mac? && OS.linux?
+  brew 'xclip'
+end
+
+if !OS.mac? && !OS.linux?
+  brew 'jq'
+end
+
+unless ENV['CI']
+  cask 'iterm2'
+end
+
+if OS.linux?
+  brew 'htop'
+end
+
+if ENV['WORK']
+  vscode 'golang.go'
+end
+`))
+		})
+	})
+
+	Describe("When reading a Brewfile which includes other Brewfiles", func() {
+		var (
+			bf    = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "src/github.com/LGUG2Z/bfm/testData/testBrewfile")
+			work  = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "src/github.com/LGUG2Z/bfm/testData/testBrewfile.work")
+			go_   = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "src/github.com/LGUG2Z/bfm/testData/testBrewfile.go")
+			files = map[string]string{
+				bf: `brew 'git'
+instance_eval(File.read("testBrewfile.work"))
+`,
+				work: `brew 'jq'
+cask 'slack'
+instance_eval(File.read("testBrewfile.go"))
+`,
+				go_: `brew 'go'
+`,
+			}
+		)
+
+		BeforeEach(func() {
+			for path, contents := range files {
+				Expect(ioutil.WriteFile(path, []byte(contents), 0644)).To(Succeed())
+			}
+		})
+
+		AfterEach(func() {
+			for path := range files {
+				os.Remove(path)
+			}
+		})
+
+		It("Reads the entries of every included Brewfile with the file they came from", func() {
+			packages := Packages{}
+			Expect(packages.FromBrewfile(bf)).To(Succeed())
+
+			Expect(packages.Brew.Lines()).To(Equal([]string{"brew 'git'", "brew 'go'", "brew 'jq'"}))
+			Expect(packages.Brew[0].File).To(Equal(""))
+			Expect(packages.Brew[1].File).To(Equal(go_))
+			Expect(packages.Brew[2].File).To(Equal(work))
+			Expect(packages.Cask[0].File).To(Equal(work))
+			Expect(packages.Files()).To(Equal([]string{bf, work, go_}))
+		})
+
+		It("Writes every entry back to the Brewfile it came from", func() {
+			packages := Packages{}
+			Expect(packages.FromBrewfile(bf)).To(Succeed())
+			packages.Brew = append(packages.Brew, Entry{Type: "brew", Name: "awscli", File: work})
+			packages.Brew.Sort()
+
+			root, err := packages.Bytes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(root)).To(Equal(`brew 'git'
+
+instance_eval(File.read("testBrewfile.work"))
+`))
+
+			included, err := packages.FileBytes(work)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(included)).To(Equal(`brew 'awscli'
+brew 'jq'
+
+cask 'slack'
+
+instance_eval(File.read("testBrewfile.go"))
+`))
+		})
+
+		It("Reports include cycles and missing Brewfiles with the position of the include", func() {
+			Expect(ioutil.WriteFile(go_, []byte(`instance_eval(File.read("testBrewfile"))
+instance_eval(File.read("testBrewfile.missing"))
+`), 0644)).To(Succeed())
+
+			packages := Packages{}
+			err := packages.FromBrewfile(bf)
+			Expect(err).To(HaveOccurred())
+
+			lines := strings.Split(err.Error(), "\n")
+			Expect(lines).To(HaveLen(2))
+			Expect(lines[0]).To(Equal(go_ + ":1:1: Include cycle: " + bf + " -> " + work + " -> " + go_ + " -> " + bf + "."))
+			Expect(lines[1]).To(HavePrefix(go_ + ":2:1: open "))
+		})
+	})
+
+	Describe("When reading a Brewfile in round-trip mode", func() {
+		var (
+			bf       = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "/src/github.com/LGUG2Z/bfm/testData/testBrewfile")
+			contents = `# Team Brewfile
+# Managed with bfm
+
+tap 'homebrew/bundle'
+cask 'firefox'
+# whitelisted for the release scripts
+brew 'jq' # keep until jo is packaged
+# brew 'emacs'
+brew 'oniguruma' # [required by: jq] pinned by ops
+# cask 'google-chrome'
+`
+		)
+
+		BeforeEach(func() {
+			ioutil.WriteFile(bf, []byte(contents), 0644)
+		})
+
+		AfterEach(func() {
+			os.Remove(bf)
+		})
+
+		It("Collects header, leading and trailing comments and commented-out entries", func() {
+			packages := Packages{KeepComments: true}
+			Expect(packages.FromBrewfile(bf)).To(Succeed())
+
+			Expect(packages.Brew.Lines()).To(Equal([]string{"brew 'jq' # keep until jo is packaged", "brew 'oniguruma' # [required by: jq] pinned by ops"}))
+			Expect(packages.Comments.Header).To(Equal([]string{"# Team Brewfile", "# Managed with bfm"}))
+
+			Expect(packages.Brew[0].Doc).To(Equal([]string{"# whitelisted for the release scripts"}))
+			Expect(packages.Brew[0].Comment).To(Equal("keep until jo is packaged"))
+			Expect(packages.Brew[1].Annotation).To(Equal("[required by: jq]"))
+			Expect(packages.Brew[1].Comment).To(Equal("pinned by ops"))
+
+			Expect(packages.Comments.Disabled["brew"].Lines()).To(Equal([]string{"# brew 'emacs'"}))
+			Expect(packages.Comments.Disabled["cask"].Lines()).To(Equal([]string{"# cask 'google-chrome'"}))
+		})
+
+		It("Reads the level marker of an entry apart from its annotation and comment", func() {
+			file, err := Parse("Brewfile", []byte(`brew 'vim' # built from source [level: build] [required by: neovim]`))
+			Expect(err).ToNot(HaveOccurred())
+
+			packages := Packages{KeepComments: true}
+			packages.FromFile(file)
+
+			Expect(packages.Brew[0].Level).To(Equal("build"))
+			Expect(packages.Brew[0].Annotation).To(Equal("[required by: neovim]"))
+			Expect(packages.Brew[0].Comment).To(Equal("built from source"))
+			Expect(packages.Brew[0].String()).To(Equal("brew 'vim' # [required by: neovim] [level: build] built from source"))
+		})
+
+		It("Reads the explicit marker of an entry and keeps the entry in the primary section", func() {
+			file, err := Parse("Brewfile", []byte("brew 'openssl' # [required by: curl] [explicit] pinned\nbrew 'curl'\nbrew 'zlib' # [required by: curl]\n"))
+			Expect(err).ToNot(HaveOccurred())
+
+			packages := Packages{KeepComments: true}
+			packages.FromFile(file)
+
+			openssl, ok := packages.Brew.Find("openssl")
+			Expect(ok).To(BeTrue())
+			Expect(openssl.Explicit).To(BeTrue())
+			Expect(openssl.Annotation).To(Equal("[required by: curl]"))
+			Expect(openssl.Comment).To(Equal("pinned"))
+
+			actual, err := packages.Bytes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(actual)).To(Equal("brew 'curl'\nbrew 'openssl' # [required by: curl] [explicit] pinned\n\nbrew 'zlib' # [required by: curl]\n"))
+		})
+
+		It("Keeps statements which are not entries as written, ahead of the entries of their block", func() {
+			file, err := Parse("Brewfile", []byte(`brew 'vim'
+# install apps for this user only
+cask_args appdir: "~/Applications", require_sha: true # keep
+cask 'firefox'
+if OS.mac?
+  cask 'iterm2'
+  cask_args  no_quarantine: true
+end
+`))
+			Expect(err).ToNot(HaveOccurred())
+
+			packages := Packages{KeepComments: true}
+			packages.FromFile(file)
+
+			actual, err := packages.Bytes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(actual)).To(Equal(`# install apps for this user only
+cask_args appdir: "~/Applications", require_sha: true # keep
+
+brew 'vim'
+
+cask 'firefox'
+
+if OS.mac?
+  cask_args  no_quarantine: true
+
+  cask 'iterm2'
+end
+`))
+		})
+
+		It("Writes the comments back out around the sorted entries", func() {
+			packages := Packages{KeepComments: true}
+			Expect(packages.FromBrewfile(bf)).To(Succeed())
+
+			actual, err := packages.Bytes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(actual)).To(Equal(`# Team Brewfile
+# Managed with bfm
+
+tap 'homebrew/bundle'
+
+# brew 'emacs'
+
+# whitelisted for the release scripts
+brew 'jq' # keep until jo is packaged
+
+brew 'oniguruma' # [required by: jq] pinned by ops
+
+cask 'firefox'
+# cask 'google-chrome'
+`))
+		})
+
+		It("Discards comments when not in round-trip mode", func() {
+			packages := Packages{}
+			Expect(packages.FromBrewfile(bf)).To(Succeed())
+			Expect(packages.Comments).To(BeNil())
+
+			actual, err := packages.Bytes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(actual)).ToNot(ContainSubstring("Team Brewfile"))
+			Expect(string(actual)).ToNot(ContainSubstring("emacs"))
+		})
+	})
 })
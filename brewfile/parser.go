@@ -0,0 +1,586 @@
+package brewfile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/LGUG2Z/bfm/diagnostics"
+	. "github.com/LGUG2Z/bfm/helpers"
+)
+
+// Reads and parses the Brewfile at the given path.
+func ParseFile(path string) (*File, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(path, b)
+}
+
+// Parses the contents of a Brewfile into a syntax tree. The filename is only
+// used to annotate positions. Parsing continues after a malformed statement so
+// that every error in the Brewfile is reported, as a diagnostics.List, along
+// with the statements which could be parsed.
+func Parse(filename string, src []byte) (*File, error) {
+	tokens, err := NewLexer(filename, string(src)).Tokens()
+
+	p := parser{src: string(src), tokens: tokens}
+	p.errs.Add(Pos{Filename: filename}, err)
+
+	nodes := p.parseStatements()
+
+	for t := p.peek(); t.Kind != EOF; t = p.peek() {
+		start := p.i
+		p.next()
+		p.recover(start, p.errorf(t, "unexpected %s", describe(t)))
+		nodes = append(nodes, p.parseStatements()...)
+	}
+
+	return &File{Path: filename, Nodes: nodes}, p.errs.Err()
+}
+
+type parser struct {
+	src    string
+	tokens []Token
+	i      int
+	errs   diagnostics.List
+}
+
+// Parses statements until the end of the file or one of the given keywords,
+// which is left unconsumed. Malformed statements are recorded and skipped.
+func (p *parser) parseStatements(terminators ...string) []Node {
+	var nodes []Node
+
+	for {
+		t := p.peek()
+
+		switch {
+		case t.Kind == EOF:
+			return nodes
+		case t.Kind == Ident && Contains(terminators, t.Value):
+			return nodes
+		case t.Kind == Newline:
+			// Statements consume their own line endings, so a newline found
+			// here is an empty line.
+			p.next()
+			nodes = append(nodes, &BlankLine{Position: t.Pos})
+			continue
+		case t.Kind == Semicolon:
+			p.next()
+			continue
+		}
+
+		start := p.i
+
+		node, err := p.parseStatement()
+		if err != nil {
+			p.recover(start, err)
+			continue
+		}
+
+		nodes = append(nodes, node)
+	}
+}
+
+// Records an error in the statement starting at the given token and skips to
+// the end of the line it ends on, including any lines spanned by brackets
+// opened before the error. An error at the position of an earlier error, such
+// as an illegal token reported by the lexer, is not recorded again.
+func (p *parser) recover(start int, err error) {
+	if d, ok := err.(*diagnostics.Diagnostic); !ok || !p.errs.At(d.Pos) {
+		p.errs.Add(Pos{}, err)
+	}
+
+	failed := p.i
+	depth := 0
+
+	for i := start; i < len(p.tokens); i++ {
+		switch t := p.tokens[i]; t.Kind {
+		case LParen, LBracket, LBrace:
+			depth++
+		case RParen, RBracket, RBrace:
+			depth--
+		case Newline:
+			if i >= failed && depth <= 0 {
+				p.i = i + 1
+				return
+			}
+		case EOF:
+			p.i = i
+			return
+		}
+	}
+}
+
+func (p *parser) parseStatement() (Node, error) {
+	t := p.peek()
+
+	switch {
+	case t.Kind == Comment:
+		p.next()
+		return &CommentLine{Position: t.Pos, Text: t.Value}, p.expectEndOfStatement()
+	case t.Kind == Ident && (t.Value == "if" || t.Value == "unless"):
+		return p.parseConditional()
+	case t.Kind == Ident && (t.Value == "end" || t.Value == "else" || t.Value == "elsif"):
+		// Keywords closing a block are only expected inside one, where they
+		// end the statements of the block before being read here.
+		p.next()
+		return nil, p.errorf(t, "unexpected '%s' without an open 'if' or 'unless'", t.Value)
+	case t.Kind == Ident:
+		return p.parseCall()
+	}
+
+	return nil, p.errorf(t, "unexpected %s", describe(t))
+}
+
+func (p *parser) parseConditional() (Node, error) {
+	keyword := p.next()
+
+	condition, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.Kind == Comment {
+		p.next()
+	}
+
+	if err := p.expectEndOfStatement(); err != nil {
+		return nil, err
+	}
+
+	keywordValue := keyword.Value
+	if keywordValue == "elsif" {
+		keywordValue = "if"
+	}
+
+	c := &Conditional{Position: keyword.Pos, Keyword: keywordValue, Condition: condition}
+
+	c.Body = p.parseStatements("else", "elsif", "end")
+
+	switch t := p.peek(); {
+	case t.Kind == Ident && t.Value == "elsif":
+		nested, err := p.parseConditional()
+		if err != nil {
+			return nil, err
+		}
+		c.Else = []Node{nested}
+		return c, nil
+	case t.Kind == Ident && t.Value == "else":
+		p.next()
+
+		if t := p.peek(); t.Kind == Comment {
+			p.next()
+		}
+
+		if err := p.expectEndOfStatement(); err != nil {
+			return nil, err
+		}
+
+		c.Else = p.parseStatements("end")
+	}
+
+	if t := p.peek(); t.Kind != Ident || t.Value != "end" {
+		return nil, p.errorf(t, "expected 'end' to close '%s' on line %d, found %s", keyword.Value, keyword.Pos.Line, describe(t))
+	}
+
+	p.next()
+
+	if t := p.peek(); t.Kind == Comment {
+		p.next()
+	}
+
+	return c, p.expectEndOfStatement()
+}
+
+// Reads the source of a condition up to the end of the line, a trailing
+// comment or a `then`.
+func (p *parser) parseCondition() (string, error) {
+	first := p.peek()
+	if endsCondition(first) {
+		return "", p.errorf(first, "expected a condition, found %s", describe(first))
+	}
+
+	last := first
+	for !endsCondition(p.peek()) {
+		last = p.next()
+	}
+
+	if t := p.peek(); t.Kind == Ident && t.Value == "then" {
+		p.next()
+	}
+
+	return strings.TrimSpace(p.src[first.Offset:last.End]), nil
+}
+
+func endsCondition(t Token) bool {
+	switch t.Kind {
+	case EOF, Newline, Comment, Semicolon:
+		return true
+	case Ident:
+		return t.Value == "then"
+	}
+
+	return false
+}
+
+func (p *parser) parseCall() (Node, error) {
+	name := p.next()
+	call := &Call{Position: name.Pos, Name: name.Value}
+
+	if t := p.peek(); t.Kind == LParen && t.Offset == name.End {
+		p.next()
+
+		if err := p.parseArguments(call, true); err != nil {
+			return nil, err
+		}
+
+		if t := p.peek(); t.Kind != RParen {
+			return nil, p.errorf(t, "expected ')', found %s", describe(t))
+		}
+		p.next()
+	} else if !p.atEndOfCall() {
+		if err := p.parseArguments(call, false); err != nil {
+			return nil, err
+		}
+	}
+
+	call.Source = p.src[name.Offset:p.tokens[p.i-1].End]
+
+	var node Node = call
+
+	if t := p.peek(); t.Kind == Ident && (t.Value == "if" || t.Value == "unless") {
+		p.next()
+
+		condition, err := p.parseCondition()
+		if err != nil {
+			return nil, err
+		}
+
+		node = &Conditional{Position: call.Position, Keyword: t.Value, Condition: condition, Body: []Node{call}, Modifier: true}
+	}
+
+	if t := p.peek(); t.Kind == Comment {
+		p.next()
+		call.Comment = t.Value
+	}
+
+	return node, p.expectEndOfStatement()
+}
+
+func (p *parser) atEndOfCall() bool {
+	t := p.peek()
+
+	switch t.Kind {
+	case EOF, Newline, Comment, Semicolon:
+		return true
+	case Ident:
+		return t.Value == "if" || t.Value == "unless"
+	}
+
+	return false
+}
+
+// Parses a comma separated list of positional arguments and options. Inside
+// parentheses, newlines are insignificant.
+func (p *parser) parseArguments(call *Call, parenthesised bool) error {
+	for {
+		if parenthesised {
+			p.skipNewlines()
+			if p.peek().Kind == RParen {
+				return nil
+			}
+		}
+
+		if err := p.parseArgument(call); err != nil {
+			return err
+		}
+
+		if parenthesised {
+			p.skipNewlines()
+		}
+
+		if p.peek().Kind != Comma {
+			return nil
+		}
+
+		p.next()
+		p.skipNewlines()
+	}
+}
+
+func (p *parser) parseArgument(call *Call) error {
+	t := p.peek()
+
+	if t.Kind == Label {
+		p.next()
+		p.skipNewlines()
+
+		value, err := p.parseValue()
+		if err != nil {
+			return err
+		}
+
+		call.Options = append(call.Options, Option{Position: t.Pos, Key: t.Value, Value: value})
+		return nil
+	}
+
+	if (t.Kind == Symbol || t.Kind == String) && p.peekAt(1).Kind == Arrow {
+		p.next()
+		p.next()
+		p.skipNewlines()
+
+		value, err := p.parseValue()
+		if err != nil {
+			return err
+		}
+
+		call.Options = append(call.Options, Option{Position: t.Pos, Key: t.Value, Value: value})
+		return nil
+	}
+
+	if len(call.Options) > 0 {
+		return p.errorf(t, "positional argument %s after options", describe(t))
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	call.Args = append(call.Args, value)
+	return nil
+}
+
+func (p *parser) parseValue() (Value, error) {
+	t := p.peek()
+
+	switch t.Kind {
+	case String:
+		p.next()
+		return &StringLit{Position: t.Pos, Value: t.Value, Quote: t.Quote, Interpolated: t.Interpolated}, nil
+	case Symbol:
+		p.next()
+		return &SymbolLit{Position: t.Pos, Name: t.Value}, nil
+	case Number:
+		p.next()
+		return &NumberLit{Position: t.Pos, Text: t.Value}, nil
+	case LBracket:
+		return p.parseArray()
+	case LBrace:
+		return p.parseHash()
+	case Ident:
+		if t.Value == "true" || t.Value == "false" {
+			p.next()
+			return &BoolLit{Position: t.Pos, Value: t.Value == "true"}, nil
+		}
+		return p.parseExpr()
+	case Colon2, Operator:
+		return p.parseExpr()
+	}
+
+	return nil, p.errorf(t, "expected a value, found %s", describe(t))
+}
+
+func (p *parser) parseArray() (Value, error) {
+	open := p.next()
+	array := &ArrayLit{Position: open.Pos}
+
+	for {
+		p.skipNewlines()
+
+		if p.peek().Kind == RBracket {
+			p.next()
+			return array, nil
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		array.Elements = append(array.Elements, value)
+
+		p.skipNewlines()
+
+		switch t := p.peek(); t.Kind {
+		case Comma:
+			p.next()
+		case RBracket:
+		default:
+			return nil, p.errorf(t, "expected ',' or ']' in array opened on line %d, found %s", open.Pos.Line, describe(t))
+		}
+	}
+}
+
+func (p *parser) parseHash() (Value, error) {
+	open := p.next()
+	hash := &HashLit{Position: open.Pos}
+
+	for {
+		p.skipNewlines()
+
+		if p.peek().Kind == RBrace {
+			p.next()
+			return hash, nil
+		}
+
+		call := &Call{}
+		if err := p.parseArgument(call); err != nil {
+			return nil, err
+		}
+
+		if len(call.Options) != 1 {
+			return nil, p.errorf(p.peek(), "expected 'key: value' in hash opened on line %d", open.Pos.Line)
+		}
+		hash.Entries = append(hash.Entries, call.Options[0])
+
+		p.skipNewlines()
+
+		switch t := p.peek(); t.Kind {
+		case Comma:
+			p.next()
+		case RBrace:
+		default:
+			return nil, p.errorf(t, "expected ',' or '}' in hash opened on line %d, found %s", open.Pos.Line, describe(t))
+		}
+	}
+}
+
+// Parses an expression made of identifiers, constants, method calls and index
+// lookups, such as `ENV['HOME']` or `File.read("Brewfile.work")`, and keeps
+// its source.
+func (p *parser) parseExpr() (Value, error) {
+	first := p.peek()
+	last := first
+
+	for p.peek().Kind == Operator && p.peek().Value == "!" {
+		last = p.next()
+	}
+
+	if t := p.peek(); t.Kind == Colon2 {
+		last = p.next()
+	}
+
+	t := p.peek()
+	if t.Kind != Ident {
+		return nil, p.errorf(t, "expected an expression, found %s", describe(t))
+	}
+	last = p.next()
+
+	for {
+		t := p.peek()
+
+		switch {
+		case t.Kind == Dot || t.Kind == Colon2:
+			p.next()
+			name := p.peek()
+			if name.Kind != Ident {
+				return nil, p.errorf(name, "expected a method or constant name, found %s", describe(name))
+			}
+			last = p.next()
+		case p.continuesExpression(t, last):
+			closing, err := p.skipBalanced()
+			if err != nil {
+				return nil, err
+			}
+			last = closing
+		default:
+			return &Expr{Position: first.Pos, Source: p.src[first.Offset:last.End]}, nil
+		}
+	}
+}
+
+// Reports whether a parenthesised argument list or index lookup directly
+// follows the previous token.
+func (p *parser) continuesExpression(t, previous Token) bool {
+	return (t.Kind == LParen || t.Kind == LBracket) && t.Offset == previous.End
+}
+
+// Skips a bracketed group of tokens, returning the closing token.
+func (p *parser) skipBalanced() (Token, error) {
+	open := p.next()
+	depth := 1
+
+	for {
+		t := p.next()
+
+		switch t.Kind {
+		case LParen, LBracket, LBrace:
+			depth++
+		case RParen, RBracket, RBrace:
+			depth--
+			if depth == 0 {
+				return t, nil
+			}
+		case EOF:
+			return t, p.errorf(open, "unclosed %s", describe(open))
+		}
+	}
+}
+
+func (p *parser) expectEndOfStatement() error {
+	switch t := p.peek(); t.Kind {
+	case Newline:
+		p.next()
+		return nil
+	case Semicolon:
+		p.next()
+		if p.peek().Kind == Newline {
+			p.next()
+		}
+		return nil
+	case EOF:
+		return nil
+	default:
+		return p.errorf(t, "unexpected %s, expected end of line", describe(t))
+	}
+}
+
+func (p *parser) skipNewlines() {
+	for p.peek().Kind == Newline || p.peek().Kind == Comment {
+		p.next()
+	}
+}
+
+func (p *parser) peek() Token {
+	return p.peekAt(0)
+}
+
+func (p *parser) peekAt(n int) Token {
+	if p.i+n >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+
+	return p.tokens[p.i+n]
+}
+
+func (p *parser) next() Token {
+	t := p.peek()
+	if p.i < len(p.tokens)-1 {
+		p.i++
+	}
+
+	return t
+}
+
+func (p *parser) errorf(t Token, format string, args ...interface{}) error {
+	return diagnostics.Errorf(t.Pos, format, args...)
+}
+
+func describe(t Token) string {
+	switch t.Kind {
+	case EOF, Newline:
+		return t.Kind.String()
+	case Comment:
+		return "comment"
+	}
+
+	// Punctuation is already named by its text.
+	if strings.HasPrefix(t.Kind.String(), "'") {
+		return t.Kind.String()
+	}
+
+	return fmt.Sprintf("%s %q", t.Kind, t.Text)
+}
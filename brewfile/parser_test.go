@@ -0,0 +1,132 @@
+package brewfile_test
+
+import (
+	. "github.com/LGUG2Z/bfm/brewfile"
+
+	"github.com/LGUG2Z/bfm/diagnostics"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Parser", func() {
+	Describe("When given the contents of a Brewfile", func() {
+		It("Produces calls with positions, arguments, options and trailing comments", func() {
+			file, err := Parse("Brewfile", []byte(`tap 'homebrew/bundle'
+  brew "vim", args: ["HEAD"], restart_service: :changed # editor
+mas 'Xcode', id: 497799835
+`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(file.Nodes).To(HaveLen(3))
+
+			brew, ok := file.Nodes[1].(*Call)
+			Expect(ok).To(BeTrue())
+			Expect(brew.Name).To(Equal("brew"))
+			Expect(brew.Pos()).To(Equal(Pos{Filename: "Brewfile", Line: 2, Column: 3}))
+			name, ok := brew.FirstString()
+			Expect(ok).To(BeTrue())
+			Expect(name).To(Equal("vim"))
+			Expect(brew.Options).To(HaveLen(2))
+			Expect(brew.Options[0].Key).To(Equal("args"))
+			Expect(brew.Options[0].Value.String()).To(Equal("['HEAD']"))
+			Expect(brew.Options[1].Value).To(BeAssignableToTypeOf(&SymbolLit{}))
+			Expect(brew.Comment).To(Equal(" editor"))
+			Expect(brew.String()).To(Equal("brew 'vim', args: ['HEAD'], restart_service: :changed # editor"))
+		})
+
+		It("Reads options spread over multiple lines and hash rocket options", func() {
+			file, err := Parse("Brewfile", []byte(`brew 'mysql@5.7',
+  link: true,
+  conflicts_with: [
+    'mysql',
+  ]
+brew('vim', :args => ['HEAD'])
+`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(file.Nodes).To(HaveLen(2))
+			Expect(file.Nodes[0].(*Call).String()).To(Equal("brew 'mysql@5.7', link: true, conflicts_with: ['mysql']"))
+			Expect(file.Nodes[1].(*Call).String()).To(Equal("brew 'vim', args: ['HEAD']"))
+		})
+
+		It("Writes interpolated strings back in double quotes with their interpolation", func() {
+			file, err := Parse("Brewfile", []byte(`brew 'vim', args: ["with-#{ENV['X']}", "HEAD", "\#{literal}"]
+`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(file.Nodes[0].(*Call).String()).To(Equal(`brew 'vim', args: ["with-#{ENV['X']}", 'HEAD', '#{literal}']`))
+		})
+
+		It("Does not mistake calls which only share a prefix with a package type", func() {
+			file, err := Parse("Brewfile", []byte("brewery 'ale'\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(file.Nodes[0].(*Call).Name).To(Equal("brewery"))
+		})
+
+		It("Keeps comment lines, blank lines and blocks in the tree", func() {
+			file, err := Parse("Brewfile", []byte(`# header
+
+if OS.mac?
+  cask 'firefox'
+else
+  brew 'firefox'
+end
+mas 'Xcode', id: 497799835 unless ENV['CI']
+`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(file.Nodes).To(HaveLen(4))
+			Expect(file.Nodes[0]).To(Equal(&CommentLine{Position: Pos{Filename: "Brewfile", Line: 1, Column: 1}, Text: " header"}))
+			Expect(file.Nodes[1]).To(BeAssignableToTypeOf(&BlankLine{}))
+
+			block := file.Nodes[2].(*Conditional)
+			Expect(block.Keyword).To(Equal("if"))
+			Expect(block.Condition).To(Equal("OS.mac?"))
+			Expect(block.Body[0].(*Call).String()).To(Equal("cask 'firefox'"))
+			Expect(block.Else[0].(*Call).String()).To(Equal("brew 'firefox'"))
+
+			modifier := file.Nodes[3].(*Conditional)
+			Expect(modifier.Modifier).To(BeTrue())
+			Expect(modifier.Keyword).To(Equal("unless"))
+			Expect(modifier.Condition).To(Equal("ENV['CI']"))
+		})
+
+		It("Returns a syntax error with the position of malformed input", func() {
+			_, err := Parse("Brewfile", []byte("brew 'vim'\nbrew 'emacs\n"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Brewfile:2:6: unterminated string"))
+
+			_, err = Parse("Brewfile", []byte("if OS.mac?\n  cask 'firefox'\n"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Brewfile:3:1: expected 'end' to close 'if' on line 1, found end of file"))
+
+			file, err := Parse("Brewfile", []byte("if OS.mac?\n  cask 'firefox'\nend\nend\nbrew 'jq'\n"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Brewfile:4:1: unexpected 'end' without an open 'if' or 'unless'"))
+
+			var names []string
+			Walk(file.Nodes, func(call *Call) {
+				names = append(names, call.Name)
+			})
+			Expect(names).To(Equal([]string{"cask", "brew"}))
+		})
+
+		It("Reports every malformed statement and keeps parsing the statements after it", func() {
+			file, err := Parse("Brewfile", []byte(`brew 'vim', args: [
+  'HEAD',,
+]
+brew 'jq'
+cask 'firefox' )
+mas 'Xcode', id: 497799835
+brew "emacs
+`))
+			Expect(err).To(HaveOccurred())
+			Expect(err).To(BeAssignableToTypeOf(diagnostics.List{}))
+			Expect(err.Error()).To(Equal("Brewfile:2:10: expected a value, found ','\nBrewfile:5:16: unexpected ')', expected end of line\nBrewfile:7:6: unterminated string"))
+
+			var names []string
+			Walk(file.Nodes, func(call *Call) {
+				name, _ := call.FirstString()
+				names = append(names, name)
+			})
+			Expect(names).To(Equal([]string{"jq", "Xcode"}))
+		})
+	})
+})
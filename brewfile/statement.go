@@ -0,0 +1,34 @@
+package brewfile
+
+import "strings"
+
+// Statement is a line of a Brewfile which is neither an entry nor an include,
+// such as `cask_args appdir: '~/Applications'`, and which is written back out as
+// it was written.
+type Statement struct {
+	// The line as written, without its comment.
+	Source    string
+	Comment   string
+	Doc       []string
+	Condition string
+	Pos       Pos
+}
+
+// Creates a Statement from a parsed call.
+func NewStatement(call *Call) Statement {
+	return Statement{
+		Source:  call.Source,
+		Comment: strings.TrimSpace(call.Comment),
+		Pos:     call.Position,
+	}
+}
+
+// Formats the statement as a Brewfile line. Leading comment lines are not
+// included.
+func (s Statement) String() string {
+	if len(s.Comment) > 0 {
+		return s.Source + " # " + s.Comment
+	}
+
+	return s.Source
+}
@@ -3,14 +3,13 @@ package cmd
 import (
 	"fmt"
 
-	"sort"
-
 	"regexp"
 
 	"github.com/LGUG2Z/bfm/brew"
 	"github.com/LGUG2Z/bfm/brewfile"
 	"github.com/boltdb/bolt"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var addFlags Flags
@@ -19,15 +18,26 @@ func init() {
 	RootCmd.AddCommand(addCmd)
 
 	addCmd.Flags().BoolVarP(&addFlags.DryRun, "dry-run", "d", false, "conduct a dry run without modifying the Brewfile")
+	addCmd.Flags().BoolVarP(&addFlags.KeepComments, "keep-comments", "k", false, "keep comments and commented-out entries when rewriting the Brewfile")
 
 	addCmd.Flags().BoolVarP(&addFlags.Tap, "tap", "t", false, "add a tap")
 	addCmd.Flags().BoolVarP(&addFlags.Brew, "brew", "b", false, "add a brew package")
 	addCmd.Flags().BoolVarP(&addFlags.Cask, "cask", "c", false, "add a cask")
 	addCmd.Flags().BoolVarP(&addFlags.Mas, "mas", "m", false, "add a mas app")
+	addCmd.Flags().BoolVarP(&addFlags.Whalebrew, "whalebrew", "w", false, "add a whalebrew image")
+	addCmd.Flags().BoolVarP(&addFlags.Vscode, "vscode", "v", false, "add a VS Code extension")
 
 	addCmd.Flags().StringSliceVar(&addFlags.Args, "args", []string{}, "args to be used during installations and updates of brews")
 	addCmd.Flags().StringVar(&addFlags.RestartService, "restart-service", "", "always (every time bundle runs), changed (after changes and updates)")
+	addCmd.Flags().BoolVar(&addFlags.StartService, "start-service", false, "start the service of a brew after installing it")
+	addCmd.Flags().StringVar(&addFlags.Link, "link", "", "true, false or overwrite, to control linking of a brew after installing it")
+	addCmd.Flags().StringSliceVar(&addFlags.ConflictsWith, "conflicts-with", []string{}, "brews to unlink before installing a brew")
+	addCmd.Flags().StringVar(&addFlags.Postinstall, "postinstall", "", "command to run after installing or upgrading a brew")
+	addCmd.Flags().StringVar(&addFlags.Level, "level", "", "dependency level of a brew, overriding BFM_LEVEL for that brew")
 	addCmd.Flags().StringVarP(&addFlags.MasID, "mas-id", "i", "", "id for mas packages (required)")
+	addCmd.Flags().StringVar(&addFlags.When, "when", "", "mac, linux or a Ruby condition for the block to add the entry to")
+	addCmd.Flags().StringVar(&addFlags.File, "file", "", "included Brewfile to add the entry to")
+	addCmd.Flags().BoolVar(&addFlags.Installed, "installed", false, "resolve dependencies from the runtime dependencies recorded for installed brews")
 }
 
 // addCmd represents the add command
@@ -37,14 +47,16 @@ var addCmd = &cobra.Command{
 	Long:  DocsAdd,
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		var packages brewfile.Packages
+		packages := brewfile.Packages{Layout: layout}
 
 		db, err := bolt.Open(boltPath, 0600, nil)
 		if err != nil {
 			errorExit(err)
 		}
 
-		cache := brew.Cache{DB: db}
+		cache := brew.Cache{DB: db, OnMiss: refreshIfStale}
+		addFlags.KeepComments = addFlags.KeepComments || viper.GetBool("keep_comments")
+		addFlags.Installed = addFlags.Installed || viper.GetBool("installed")
 
 		err = Add(args, &packages, cache, brewfilePath, addFlags, level)
 		errorExit(err)
@@ -59,25 +71,40 @@ func Add(args []string, packages *brewfile.Packages, cache brew.Cache, brewfileP
 	toAdd := args[0]
 	packageType := getPackageType(flags)
 
+	if flags.Brew {
+		toAdd = canonicalName(cache, toAdd)
+		if toAdd != args[0] {
+			fmt.Printf("%s is known to Homebrew as %s.\n", args[0], toAdd)
+		}
+	}
+
+	packages.KeepComments = flags.KeepComments
+
 	if err := packages.FromBrewfile(brewfilePath); err != nil {
 		return err
 	}
 
-	b, err := packages.Bytes()
+	if packages.Contains(packageType, toAdd) {
+		return ErrEntryAlreadyExists(toAdd)
+	}
+
+	file, err := includedFile(packages, flags.File)
 	if err != nil {
 		return err
 	}
 
-	if entryExists(string(b), packageType, toAdd) {
-		return ErrEntryAlreadyExists(toAdd)
-	}
+	flags.File = file
 
-	cacheMap := brew.CacheMap{Cache: &cache, Map: make(brew.Map)}
+	cacheMap := brew.CacheMap{Cache: &cache, Map: make(brew.Map), Casks: make(brew.Map), Installed: flags.Installed}
 
 	if err := cacheMap.FromPackages(packages.Brew); err != nil {
 		return err
 	}
 
+	if err := cacheMap.FromCasks(packages.Cask); err != nil {
+		return err
+	}
+
 	if err := cacheMap.ResolveDependencyMap(level); err != nil {
 		return err
 	}
@@ -87,11 +114,11 @@ func Add(args []string, packages *brewfile.Packages, cache brew.Cache, brewfileP
 			return ErrInvalidTapFormat
 		}
 		packages.Tap = addPackage(packageType, toAdd, packages.Tap, flags)
-		sort.Strings(packages.Tap)
+		packages.Tap.Sort()
 	}
 
 	if flags.Brew {
-		updated, err := addBrewPackage(toAdd, flags.RestartService, flags.Args, cacheMap, flags, level)
+		updated, err := addBrewPackage(toAdd, cacheMap, flags, level, packages.Layout)
 		if err != nil {
 			return err
 		}
@@ -100,7 +127,28 @@ func Add(args []string, packages *brewfile.Packages, cache brew.Cache, brewfileP
 
 	if flags.Cask {
 		packages.Cask = addPackage(packageType, toAdd, packages.Cask, flags)
-		sort.Strings(packages.Cask)
+
+		cask, _ := packages.Cask.Find(toAdd)
+		if err := cacheMap.AddCask(cask, level); err != nil {
+			return err
+		}
+
+		brews, err := cleanBrews(cacheMap, packages.Layout)
+		if err != nil {
+			return err
+		}
+
+		packages.Brew = brews
+	}
+
+	if flags.Brew || flags.Cask {
+		casks, err := cleanCasks(cacheMap, packages.Layout)
+		if err != nil {
+			return err
+		}
+
+		packages.Cask = casks
+		packages.Tap = addRequiredTap(toAdd, packages.Tap, flags)
 	}
 
 	if flags.Mas {
@@ -109,15 +157,31 @@ func Add(args []string, packages *brewfile.Packages, cache brew.Cache, brewfileP
 		}
 
 		packages.Mas = addPackage(packageType, toAdd, packages.Mas, flags)
-		sort.Strings(packages.Mas)
+		packages.Mas.Sort()
+	}
+
+	if flags.Whalebrew {
+		if !hasCorrectWhalebrewFormat(toAdd) {
+			return ErrInvalidWhalebrewFormat
+		}
+
+		packages.Whalebrew = addPackage(packageType, toAdd, packages.Whalebrew, flags)
+		packages.Whalebrew.Sort()
+	}
+
+	if flags.Vscode {
+		if !hasCorrectVscodeFormat(toAdd) {
+			return ErrInvalidVscodeFormat
+		}
+
+		packages.Vscode = addPackage(packageType, toAdd, packages.Vscode, flags)
+		packages.Vscode.Sort()
 	}
 
 	if flags.DryRun {
-		b, err := packages.Bytes()
-		if err != nil {
+		if err := printPackages(packages); err != nil {
 			return err
 		}
-		fmt.Printf(string(b))
 	} else {
 		if err := writeToFile(brewfilePath, packages); err != nil {
 			return err
@@ -127,47 +191,87 @@ func Add(args []string, packages *brewfile.Packages, cache brew.Cache, brewfileP
 	return nil
 }
 
-func addBrewPackage(add, restart string, args []string, cacheMap brew.CacheMap, flags Flags, level int) ([]string, error) {
-	if len(restart) > 1 {
-		switch restart {
+func addBrewPackage(add string, cacheMap brew.CacheMap, flags Flags, level int, layout *brewfile.Layout) (brewfile.Entries, error) {
+	entry, err := newBrewEntry(add, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := cacheMap.Conflicts()
+	if err := cacheMap.Add(entry, level); err != nil {
+		return nil, err
+	}
+
+	if err := cacheMap.CheckConflicts(conflicts); err != nil {
+		return nil, err
+	}
+
+	entries, err := cleanBrews(cacheMap, layout)
+	if err != nil {
+		return nil, err
+	}
+
+	if !flags.DryRun {
+		fmt.Printf("Added %s '%s' to Brewfile.\n", "brew", add)
+	}
+
+	return entries, nil
+}
+
+// Creates a brew Entry with the options given as flags.
+func newBrewEntry(name string, flags Flags) (brew.Entry, error) {
+	entry := brew.Entry{
+		Name:          name,
+		Args:          flags.Args,
+		ConflictsWith: flags.ConflictsWith,
+		Postinstall:   flags.Postinstall,
+		Condition:     brewfile.ConditionFor(flags.When),
+		File:          flags.File,
+	}
+
+	if len(flags.RestartService) > 0 {
+		switch flags.RestartService {
 		case "always":
-			restart = "true"
+			entry.RestartService = "true"
 		case "changed":
-			restart = ":changed"
+			entry.RestartService = ":changed"
 		default:
-			return []string{}, ErrInvalidRestartServiceOption
+			return brew.Entry{}, ErrInvalidRestartServiceOption
 		}
 	}
 
-	if err := cacheMap.Add(brew.Entry{Name: add, RestartService: restart, Args: args}, level); err != nil {
-		return []string{}, err
-	}
-
-	lines := []string{}
-
-	for _, b := range cacheMap.Map {
-		entry, err := b.Format()
-		if err != nil {
-			return []string{}, err
+	if len(flags.Link) > 0 {
+		switch flags.Link {
+		case "true", "false":
+			entry.Link = flags.Link
+		case "overwrite":
+			entry.Link = ":overwrite"
+		default:
+			return brew.Entry{}, ErrInvalidLinkOption
 		}
+	}
 
-		lines = append(lines, entry)
+	if flags.StartService {
+		entry.StartService = "true"
 	}
 
-	sort.Strings(lines)
+	if len(flags.Level) > 0 {
+		level, ok := brew.ParseLevel(flags.Level)
+		if !ok {
+			return brew.Entry{}, ErrInvalidLevelOption
+		}
 
-	if !flags.DryRun {
-		fmt.Printf("Added %s '%s' to Brewfile.\n", "brew", add)
+		entry.Level = brew.FormatLevel(level)
 	}
 
-	return lines, nil
+	return entry, nil
 }
 
-func addPackage(packageType, newPackage string, packages []string, flags Flags) []string {
-	packageEntry := constructBaseEntry(packageType, newPackage)
+func addPackage(packageType, newPackage string, packages brewfile.Entries, flags Flags) brewfile.Entries {
+	packageEntry := brewfile.Entry{Type: packageType, Name: newPackage, Condition: brewfile.ConditionFor(flags.When), File: flags.File}
 
 	if packageType == "mas" {
-		packageEntry = appendMasID(packageEntry, flags.MasID)
+		packageEntry.Options = packageEntry.Options.Set("id", &brewfile.NumberLit{Text: flags.MasID})
 	}
 
 	if !flags.DryRun {
@@ -177,15 +281,35 @@ func addPackage(packageType, newPackage string, packages []string, flags Flags)
 	return append(packages, packageEntry)
 }
 
+// Adds the tap an added brew or cask comes from, going by its full name, to the
+// taps of the Brewfile, in the same block and Brewfile as the brew or cask, unless
+// it is already tapped.
+func addRequiredTap(name string, taps brewfile.Entries, flags Flags) brewfile.Entries {
+	t := brew.RequiredTap(name)
+	if len(t) < 1 || hasTap(taps, t) {
+		return taps
+	}
+
+	taps = addPackage("tap", t, taps, flags)
+	taps.Sort()
+	return taps
+}
+
 func hasCorrectTapFormat(tap string) bool {
 	result, _ := regexp.MatchString(`.+/.+`, tap)
 	return result
 }
 
-func hasMasID(i string) bool {
-	return len(i) > 0
+func hasCorrectWhalebrewFormat(image string) bool {
+	result, _ := regexp.MatchString(`.+/.+`, image)
+	return result
 }
 
-func appendMasID(packageEntry, i string) string {
-	return fmt.Sprintf("%s, id: %s", packageEntry, i)
+func hasCorrectVscodeFormat(extension string) bool {
+	result, _ := regexp.MatchString(`.+\..+`, extension)
+	return result
+}
+
+func hasMasID(i string) bool {
+	return len(i) > 0
 }
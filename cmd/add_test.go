@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 
 	"github.com/LGUG2Z/bfm/brew"
 	"github.com/LGUG2Z/bfm/brewfile"
@@ -84,6 +85,33 @@ cask 'firefox'
 		})
 	})
 
+	Describe("When the command is called for a brew given by an alias", func() {
+		It("Should add the brew under the current name of its formula", func() {
+			Expect(cache.Refresh(exec.Command("echo", `[ { "full_name": "python@3.9", "aliases": ["python3"] } ]`))).To(Succeed())
+
+			output := captureStdout(func() {
+				Expect(Add([]string{"python3"}, &packages, cache, bf, Flags{Brew: true}, brew.Required)).To(Succeed())
+			})
+			Expect(output).To(Equal("python3 is known to Homebrew as python@3.9.\nAdded brew 'python@3.9' to Brewfile.\n"))
+
+			bytes, err := ioutil.ReadFile(bf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("brew 'python@3.9'\n"))
+
+			err = Add([]string{"python3"}, &brewfile.Packages{}, cache, bf, Flags{Brew: true}, brew.Required)
+			Expect(err).To(Equal(ErrEntryAlreadyExists("python@3.9")))
+		})
+
+		It("Should say which name the brew is known by in a dry run too", func() {
+			Expect(cache.Refresh(exec.Command("echo", `[ { "full_name": "python@3.9", "aliases": ["python3"] } ]`))).To(Succeed())
+
+			output := captureStdout(func() {
+				Expect(Add([]string{"python3"}, &brewfile.Packages{}, cache, bf, Flags{Brew: true, DryRun: true}, brew.Required)).To(Succeed())
+			})
+			Expect(output).To(Equal("python3 is known to Homebrew as python@3.9.\nbrew 'python@3.9'\n"))
+		})
+	})
+
 	Describe("When the command is called for a tap", func() {
 		It("Should return an error if the tap format is not user/repo", func() {
 			error := Add([]string{"bad:format"}, &brewfile.Packages{}, cache, bf, Flags{Tap: true}, 0)
@@ -138,6 +166,132 @@ cask 'firefox'
 			Expect(bytes).To(Equal([]byte("cask 'firefox'\n")))
 
 		})
+
+		It("Should add the brews the cask depends on, annotated with the cask", func() {
+			var thing brew.CaskInfo
+			thing.Token, thing.DependsOn.Formula = "docker-thing", []string{"jq"}
+			Expect(db.AddTestCasksFromInfo(thing)).To(Succeed())
+			Expect(db.AddTestBrewsByName("jq")).To(Succeed())
+
+			_ = captureStdout(func() {
+				Expect(Add([]string{"docker-thing"}, &brewfile.Packages{}, cache, bf, Flags{Cask: true}, brew.Required)).To(Succeed())
+			})
+
+			bytes, err := ioutil.ReadFile(bf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("brew 'jq' # [required by: docker-thing]\n\ncask 'docker-thing'\n"))
+		})
+	})
+
+	Describe("When the command is called for a whalebrew image", func() {
+		It("Should return an error if the image format is not user/image", func() {
+			error := Add([]string{"wget"}, &brewfile.Packages{}, cache, bf, Flags{Whalebrew: true}, 0)
+			Expect(error).To(HaveOccurred())
+			Expect(error).To(Equal(ErrInvalidWhalebrewFormat))
+		})
+
+		It("Should add a whalebrew image to the Brewfile after the other sections", func() {
+			f := TestFile{Path: bf, Contents: "cask 'firefox'\nvscode 'golang.go'\n"}
+			Expect(f.Create()).To(Succeed())
+
+			_ = captureStdout(func() {
+				Expect(Add([]string{"whalebrew/wget"}, &brewfile.Packages{}, cache, bf, Flags{Whalebrew: true}, 0)).To(Succeed())
+			})
+
+			bytes, error := ioutil.ReadFile(bf)
+			Expect(error).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("cask 'firefox'\n\nwhalebrew 'whalebrew/wget'\n\nvscode 'golang.go'\n"))
+		})
+	})
+
+	Describe("When the command is called for a VS Code extension", func() {
+		It("Should return an error if the extension format is not publisher.extension", func() {
+			error := Add([]string{"go"}, &brewfile.Packages{}, cache, bf, Flags{Vscode: true}, 0)
+			Expect(error).To(HaveOccurred())
+			Expect(error).To(Equal(ErrInvalidVscodeFormat))
+		})
+
+		It("Should add a VS Code extension to the Brewfile", func() {
+			_ = captureStdout(func() {
+				Expect(Add([]string{"golang.go"}, &brewfile.Packages{}, cache, bf, Flags{Vscode: true}, 0)).To(Succeed())
+			})
+
+			bytes, error := ioutil.ReadFile(bf)
+			Expect(error).ToNot(HaveOccurred())
+			Expect(bytes).To(Equal([]byte("vscode 'golang.go'\n")))
+		})
+	})
+
+	Describe("When called with the --when flag", func() {
+		It("Should add the entry to the block of an existing condition", func() {
+			db.AddTestBrewsByName("git")
+
+			f := TestFile{Path: bf, Contents: "brew 'git'\nif OS.mac?\n  cask 'firefox'\nend\n"}
+			Expect(f.Create()).To(Succeed())
+
+			_ = captureStdout(func() {
+				Expect(Add([]string{"iterm2"}, &brewfile.Packages{}, cache, bf, Flags{Cask: true, When: "mac"}, 0)).To(Succeed())
+			})
+
+			bytes, error := ioutil.ReadFile(bf)
+			Expect(error).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("brew 'git'\n\nif OS.mac?\n  cask 'firefox'\n  cask 'iterm2'\nend\n"))
+		})
+
+		It("Should add a brew and its dependencies to a new block", func() {
+			db.AddTestBrewsByName("xsel")
+			db.AddTestBrewsFromInfo(brew.Info{FullName: "xclip", Dependencies: []string{"xsel"}})
+
+			packages := &brewfile.Packages{}
+
+			Expect(Add([]string{"xclip"}, packages, cache, bf, Flags{Brew: true, When: "linux", DryRun: true}, brew.Required)).To(Succeed())
+
+			Expect(packages.Bytes()).To(Equal([]byte("if OS.linux?\n  brew 'xclip'\n\n  brew 'xsel' # [required by: xclip]\nend\n")))
+		})
+	})
+
+	Describe("When called with the --file flag", func() {
+		var work = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "src/github.com/LGUG2Z/bfm/testData/testBrewfile.work")
+
+		BeforeEach(func() {
+			f := TestFile{Path: bf, Contents: "cask 'firefox'\ninstance_eval(File.read('testBrewfile.work'))\n"}
+			Expect(f.Create()).To(Succeed())
+
+			w := TestFile{Path: work, Contents: "cask 'slack'\n"}
+			Expect(w.Create()).To(Succeed())
+		})
+
+		AfterEach(func() {
+			os.Remove(work)
+		})
+
+		It("Should add the entry to the included Brewfile", func() {
+			_ = captureStdout(func() {
+				Expect(Add([]string{"zoom"}, &brewfile.Packages{}, cache, bf, Flags{Cask: true, File: "testBrewfile.work"}, 0)).To(Succeed())
+			})
+
+			bytes, error := ioutil.ReadFile(bf)
+			Expect(error).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("cask 'firefox'\n\ninstance_eval(File.read('testBrewfile.work'))\n"))
+
+			bytes, error = ioutil.ReadFile(work)
+			Expect(error).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("cask 'slack'\ncask 'zoom'\n"))
+		})
+
+		It("Should return an error if the file is not included by the Brewfile", func() {
+			error := Add([]string{"zoom"}, &brewfile.Packages{}, cache, bf, Flags{Cask: true, File: "Brewfile.other"}, 0)
+			Expect(error).To(HaveOccurred())
+			Expect(error).To(Equal(brewfile.ErrNotIncluded("Brewfile.other")))
+		})
+
+		It("Should print every Brewfile during a dry run", func() {
+			output := captureStdout(func() {
+				Expect(Add([]string{"zoom"}, &brewfile.Packages{}, cache, bf, Flags{Cask: true, File: work, DryRun: true}, 0)).To(Succeed())
+			})
+
+			Expect(output).To(Equal("cask 'firefox'\n\ninstance_eval(File.read('testBrewfile.work'))\n\n==> " + work + " <==\ncask 'slack'\ncask 'zoom'\n"))
+		})
 	})
 
 	Describe("When called for a brew with the --restart-service flag", func() {
@@ -158,7 +312,7 @@ cask 'firefox'
 			Expect(Add([]string{"a2ps"}, packages, cache, bf, Flags{Brew: true, RestartService: "always"}, 0)).To(Succeed())
 
 			Expect(packages.Brew).ToNot(BeEmpty())
-			Expect(packages.Brew[0]).To(ContainSubstring("restart_service: true"))
+			Expect(packages.Brew[0].String()).To(ContainSubstring("restart_service: true"))
 
 		})
 
@@ -170,7 +324,7 @@ cask 'firefox'
 			Expect(Add([]string{"a2ps"}, packages, cache, bf, Flags{Brew: true, RestartService: "changed"}, 0)).To(Succeed())
 
 			Expect(packages.Brew).ToNot(BeEmpty())
-			Expect(packages.Brew[0]).To(ContainSubstring("restart_service: :changed"))
+			Expect(packages.Brew[0].String()).To(ContainSubstring("restart_service: :changed"))
 
 		})
 	})
@@ -184,8 +338,130 @@ cask 'firefox'
 			Expect(Add([]string{"a2ps"}, packages, cache, bf, Flags{Brew: true, Args: []string{"one", "two"}}, 0)).To(Succeed())
 
 			Expect(packages.Brew).ToNot(BeEmpty())
-			Expect(packages.Brew[0]).To(ContainSubstring("args: ['one', 'two']"))
+			Expect(packages.Brew[0].String()).To(ContainSubstring("args: ['one', 'two']"))
+
+		})
+	})
+
+	Describe("When called for a brew with the --link, --conflicts-with, --start-service and --postinstall flags", func() {
+		It("Should return an error explaining the valid options if an invalid --link option is given", func() {
+			db.AddTestBrewsByName("mysql@5.7")
+
+			err := Add([]string{"mysql@5.7"}, &brewfile.Packages{}, cache, bf, Flags{Brew: true, Link: "sometimes"}, 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err).To(Equal(ErrInvalidLinkOption))
+		})
+
+		It("Should add brew with all of the given options", func() {
+			db.AddTestBrewsByName("mysql@5.7")
+
+			packages := &brewfile.Packages{}
+			flags := Flags{Brew: true, Link: "true", ConflictsWith: []string{"mysql"}, StartService: true, Postinstall: "mysql.server start"}
+
+			Expect(Add([]string{"mysql@5.7"}, packages, cache, bf, flags, 0)).To(Succeed())
+
+			Expect(packages.Brew).To(HaveLen(1))
+			Expect(packages.Brew[0].String()).To(Equal("brew 'mysql@5.7', link: true, conflicts_with: ['mysql'], start_service: true, postinstall: 'mysql.server start'"))
+		})
+	})
+
+	Describe("When called for a brew conflicting with a brew in the Brewfile", func() {
+		It("Should refuse to add the brew, reporting the conflicting pair and the dependencies bringing them in", func() {
+			f = TestFile{Path: bf, Contents: "brew 'mysql'\n"}
+			Expect(f.Create()).To(Succeed())
+
+			Expect(db.AddTestBrewsFromInfo(
+				brew.Info{FullName: "wordpress", Dependencies: []string{"mariadb"}},
+				brew.Info{FullName: "mariadb", ConflictsWith: []string{"mysql"}},
+				brew.Info{FullName: "mysql", ConflictsWith: []string{"mariadb"}},
+			)).To(Succeed())
+
+			err := Add([]string{"wordpress"}, &brewfile.Packages{}, cache, bf, Flags{Brew: true}, brew.Required)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("mariadb conflicts with mysql.\n" +
+				"  mariadb comes in through wordpress -> mariadb (required).\n" +
+				"  mysql is a primary brew.\n" +
+				"Remove one of the conflicting brews, or list the other in the conflicts_with option of one of them."))
+
+			bytes, err := ioutil.ReadFile(bf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("brew 'mysql'\n"))
+
+			flags := Flags{Brew: true, ConflictsWith: []string{"mysql"}}
+			Expect(Add([]string{"mariadb"}, &brewfile.Packages{}, cache, bf, flags, brew.Required)).To(Succeed())
+		})
+	})
+
+	Describe("When called for a brew with the --level flag", func() {
+		It("Should return an error if an invalid level is given", func() {
+			err := Add([]string{"vim"}, &brewfile.Packages{}, cache, bf, Flags{Brew: true, Level: "everything"}, 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err).To(Equal(ErrInvalidLevelOption))
+		})
+
+		It("Should add a brew marked with its level along with the dependencies of that level", func() {
+			db.AddTestBrewsFromInfo(
+				brew.Info{FullName: "vim", Dependencies: []string{"python", "go"}, BuildDependencies: []string{"go"}},
+				brew.Info{FullName: "python"},
+				brew.Info{FullName: "go"},
+			)
+
+			packages := &brewfile.Packages{}
+
+			_ = captureStdout(func() {
+				Expect(Add([]string{"vim"}, packages, cache, bf, Flags{Brew: true, Level: "Build"}, brew.Required)).To(Succeed())
+			})
+
+			Expect(packages.Brew.Lines()).To(Equal([]string{
+				"brew 'go' # [build for: vim]",
+				"brew 'python' # [required by: vim]",
+				"brew 'vim' # [level: build]",
+			}))
+		})
+	})
+
+	Describe("When called for a brew from a tap", func() {
+		It("Should add the tap of the brew unless already tapped", func() {
+			f = TestFile{Path: bf, Contents: "tap 'koekeishiya/homebrew-formulae'\n"}
+			Expect(f.Create()).To(Succeed())
+
+			Expect(db.AddTestBrewsFromInfo(
+				brew.Info{FullName: "crisidev/chunkwm/chunkwm", Dependencies: []string{"koekeishiya/formulae/skhd", "jq"}},
+				brew.Info{FullName: "koekeishiya/formulae/skhd"},
+				brew.Info{FullName: "jq"},
+			)).To(Succeed())
+
+			output := captureStdout(func() {
+				Expect(Add([]string{"crisidev/chunkwm/chunkwm"}, &brewfile.Packages{}, cache, bf, Flags{Brew: true}, brew.Required)).To(Succeed())
+			})
+			Expect(output).To(Equal("Added brew 'crisidev/chunkwm/chunkwm' to Brewfile.\nAdded tap 'crisidev/chunkwm' to Brewfile.\n"))
+
+			bytes, err := ioutil.ReadFile(bf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("tap 'crisidev/chunkwm'\ntap 'koekeishiya/homebrew-formulae'\n\n" +
+				"brew 'crisidev/chunkwm/chunkwm'\n\n" +
+				"brew 'jq' # [required by: crisidev/chunkwm/chunkwm]\n" +
+				"brew 'koekeishiya/formulae/skhd' # [required by: crisidev/chunkwm/chunkwm]\n"))
+		})
+
+		It("Should add the tap in the block the brew is added to", func() {
+			f = TestFile{Path: bf, Contents: "brew 'jq'\n"}
+			Expect(f.Create()).To(Succeed())
+
+			Expect(db.AddTestBrewsFromInfo(
+				brew.Info{FullName: "crisidev/chunkwm/chunkwm"},
+				brew.Info{FullName: "jq"},
+				brew.Info{FullName: "other/tap/tool"},
+			)).To(Succeed())
+
+			packages := &brewfile.Packages{}
+			_ = captureStdout(func() {
+				Expect(Add([]string{"crisidev/chunkwm/chunkwm"}, packages, cache, bf, Flags{Brew: true, When: "mac", DryRun: true}, brew.Required)).To(Succeed())
+			})
 
+			Expect(packages.Tap).To(HaveLen(1))
+			Expect(packages.Tap[0].Name).To(Equal("crisidev/chunkwm"))
+			Expect(packages.Tap[0].Condition).To(Equal("if OS.mac?"))
 		})
 	})
 
@@ -199,8 +475,8 @@ cask 'firefox'
 			Expect(Add([]string{"a2ps"}, packages, cache, bf, Flags{Brew: true}, brew.Required)).To(Succeed())
 
 			Expect(packages.Brew).To(HaveLen(2))
-			Expect(packages.Brew[0]).To(Equal("brew 'a2ps'"))
-			Expect(packages.Brew[1]).To(Equal("brew 'bash' # [required by: a2ps]"))
+			Expect(packages.Brew[0].String()).To(Equal("brew 'a2ps'"))
+			Expect(packages.Brew[1].String()).To(Equal("brew 'bash' # [required by: a2ps]"))
 		})
 	})
 
@@ -214,9 +490,9 @@ cask 'firefox'
 			Expect(Add([]string{"a2ps"}, packages, cache, bf, Flags{Brew: true}, brew.Recommended)).To(Succeed())
 
 			Expect(packages.Brew).To(HaveLen(3))
-			Expect(packages.Brew[0]).To(Equal("brew 'a2ps'"))
-			Expect(packages.Brew[1]).To(Equal("brew 'bash' # [required by: a2ps]"))
-			Expect(packages.Brew[2]).To(Equal("brew 'zsh' # [recommended for: a2ps]"))
+			Expect(packages.Brew[0].String()).To(Equal("brew 'a2ps'"))
+			Expect(packages.Brew[1].String()).To(Equal("brew 'bash' # [required by: a2ps]"))
+			Expect(packages.Brew[2].String()).To(Equal("brew 'zsh' # [recommended for: a2ps]"))
 		})
 	})
 
@@ -235,10 +511,10 @@ cask 'firefox'
 			Expect(Add([]string{"a2ps"}, packages, cache, bf, Flags{Brew: true}, brew.Optional)).To(Succeed())
 
 			Expect(packages.Brew).To(HaveLen(4))
-			Expect(packages.Brew[0]).To(Equal("brew 'a2ps'"))
-			Expect(packages.Brew[1]).To(Equal("brew 'bash' # [required by: a2ps]"))
-			Expect(packages.Brew[2]).To(Equal("brew 'fish' # [optional for: a2ps]"))
-			Expect(packages.Brew[3]).To(Equal("brew 'zsh' # [recommended for: a2ps]"))
+			Expect(packages.Brew[0].String()).To(Equal("brew 'a2ps'"))
+			Expect(packages.Brew[1].String()).To(Equal("brew 'bash' # [required by: a2ps]"))
+			Expect(packages.Brew[2].String()).To(Equal("brew 'fish' # [optional for: a2ps]"))
+			Expect(packages.Brew[3].String()).To(Equal("brew 'zsh' # [recommended for: a2ps]"))
 		})
 
 	})
@@ -259,11 +535,11 @@ cask 'firefox'
 			Expect(Add([]string{"a2ps"}, packages, cache, bf, Flags{Brew: true}, brew.Build)).To(Succeed())
 
 			Expect(packages.Brew).To(HaveLen(5))
-			Expect(packages.Brew[0]).To(Equal("brew 'a2ps'"))
-			Expect(packages.Brew[1]).To(Equal("brew 'bash' # [required by: a2ps]"))
-			Expect(packages.Brew[2]).To(Equal("brew 'fish' # [optional for: a2ps]"))
-			Expect(packages.Brew[3]).To(Equal("brew 'sh' # [build for: a2ps]"))
-			Expect(packages.Brew[4]).To(Equal("brew 'zsh' # [recommended for: a2ps]"))
+			Expect(packages.Brew[0].String()).To(Equal("brew 'a2ps'"))
+			Expect(packages.Brew[1].String()).To(Equal("brew 'bash' # [required by: a2ps]"))
+			Expect(packages.Brew[2].String()).To(Equal("brew 'fish' # [optional for: a2ps]"))
+			Expect(packages.Brew[3].String()).To(Equal("brew 'sh' # [build for: a2ps]"))
+			Expect(packages.Brew[4].String()).To(Equal("brew 'zsh' # [recommended for: a2ps]"))
 		})
 	})
 })
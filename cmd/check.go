@@ -12,6 +12,7 @@ import (
 	"github.com/LGUG2Z/bfm/brewfile"
 	"github.com/boltdb/bolt"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var checkFlags Flags
@@ -23,6 +24,9 @@ func init() {
 	checkCmd.Flags().BoolVarP(&checkFlags.Brew, "brew", "b", false, "check a brew package")
 	checkCmd.Flags().BoolVarP(&checkFlags.Cask, "cask", "c", false, "check a cask")
 	checkCmd.Flags().BoolVarP(&checkFlags.Mas, "mas", "m", false, "check a mas app")
+	checkCmd.Flags().BoolVarP(&checkFlags.Whalebrew, "whalebrew", "w", false, "check a whalebrew image")
+	checkCmd.Flags().BoolVarP(&checkFlags.Vscode, "vscode", "v", false, "check a VS Code extension")
+	checkCmd.Flags().BoolVar(&checkFlags.Installed, "installed", false, "resolve dependencies from the runtime dependencies recorded for installed brews")
 }
 
 // checkCmd represents the check command
@@ -32,14 +36,15 @@ var checkCmd = &cobra.Command{
 	Long:  DocsCheck,
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		var packages brewfile.Packages
+		packages := brewfile.Packages{Layout: layout}
 
 		db, err := bolt.Open(boltPath, 0600, nil)
 		if err != nil {
 			errorExit(err)
 		}
 
-		cache := brew.Cache{DB: db}
+		cache := brew.Cache{DB: db, OnMiss: refreshIfStale}
+		checkFlags.Installed = checkFlags.Installed || viper.GetBool("installed")
 
 		err = Check(args, &packages, cache, brewfilePath, checkFlags, level)
 		errorExit(err)
@@ -47,7 +52,7 @@ var checkCmd = &cobra.Command{
 }
 
 func Check(args []string, packages *brewfile.Packages, cache brew.Cache, brewfilePath string, flags Flags, level int) error {
-	if !flagProvided(checkFlags) {
+	if !flagProvided(flags) {
 		return ErrNoPackageType("check")
 	}
 
@@ -56,9 +61,13 @@ func Check(args []string, packages *brewfile.Packages, cache brew.Cache, brewfil
 	}
 
 	toCheck := args[0]
-	packageType := getPackageType(checkFlags)
+	packageType := getPackageType(flags)
 
-	cacheMap := brew.CacheMap{Cache: &cache, Map: make(brew.Map)}
+	if flags.Brew {
+		toCheck = canonicalName(cache, toCheck)
+	}
+
+	cacheMap := brew.CacheMap{Cache: &cache, Map: make(brew.Map), Installed: flags.Installed}
 
 	if err := cacheMap.FromPackages(packages.Brew); err != nil {
 		return err
@@ -68,12 +77,7 @@ func Check(args []string, packages *brewfile.Packages, cache brew.Cache, brewfil
 		return err
 	}
 
-	b, err := packages.Bytes()
-	if err != nil {
-		return err
-	}
-
-	if entryExists(string(b), packageType, toCheck) {
+	if packages.Contains(packageType, toCheck) || packages.Contains(packageType, args[0]) {
 		switch packageType {
 		case "brew":
 
@@ -145,11 +149,17 @@ Not a required, recommended, optional or build dependency of any other package.
 			fmt.Println(presenceBytes.String())
 			fmt.Println(dependenciesBytes.String())
 			fmt.Println(dependencyOfBytes.String())
+
+			for _, conflict := range cacheMap.Conflicts() {
+				if conflict.Name == toCheck || conflict.With == toCheck {
+					fmt.Println(conflict)
+				}
+			}
 		default:
 			fmt.Printf("%s is present in the Brewfile.\n", toCheck)
 		}
 	} else {
-		fmt.Printf("%s is not present in the Brewfile.\n", toCheck)
+		fmt.Printf("%s is not present in the Brewfile.\n", args[0])
 	}
 
 	return nil
@@ -0,0 +1,59 @@
+package cmd_test
+
+import (
+	. "github.com/LGUG2Z/bfm/cmd"
+
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/LGUG2Z/bfm/brew"
+	"github.com/LGUG2Z/bfm/brewfile"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Check", func() {
+	var (
+		bf     = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "src/github.com/LGUG2Z/bfm/testData/testBrewfile")
+		dbFile = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "src/github.com/LGUG2Z/bfm/testData/testDB.bolt")
+		cache  brew.Cache
+		f      TestFile
+		db     *TestDB
+	)
+
+	BeforeEach(func() {
+		f = TestFile{Path: bf, Contents: "brew 'python@3.9'\ncask 'firefox'\n"}
+		Expect(f.Create()).To(Succeed())
+
+		testDB, err := NewTestDB(dbFile)
+		db = testDB
+		Expect(err).ToNot(HaveOccurred())
+		cache.DB = db.DB
+
+		Expect(cache.Refresh(exec.Command("echo", `[ { "full_name": "python@3.9", "aliases": ["python3"] } ]`))).To(Succeed())
+	})
+
+	AfterEach(func() {
+		f.Remove()
+		db.Close()
+	})
+
+	Describe("When the command is called", func() {
+		It("Should find a brew checked by an alias under the current name of its formula", func() {
+			output := captureStdout(func() {
+				Expect(Check([]string{"python3"}, &brewfile.Packages{}, cache, bf, Flags{Brew: true}, brew.Required)).To(Succeed())
+			})
+
+			Expect(output).To(HavePrefix("python@3.9 is present in the Brewfile.\n"))
+		})
+
+		It("Should say when a package is not present in the Brewfile", func() {
+			output := captureStdout(func() {
+				Expect(Check([]string{"chromium"}, &brewfile.Packages{}, cache, bf, Flags{Cask: true}, brew.Required)).To(Succeed())
+			})
+
+			Expect(output).To(Equal("chromium is not present in the Brewfile.\n"))
+		})
+	})
+})
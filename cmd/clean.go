@@ -3,12 +3,11 @@ package cmd
 import (
 	"fmt"
 
-	"sort"
-
 	"github.com/LGUG2Z/bfm/brew"
 	"github.com/LGUG2Z/bfm/brewfile"
 	"github.com/boltdb/bolt"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var cleanFlags Flags
@@ -16,6 +15,8 @@ var cleanFlags Flags
 func init() {
 	RootCmd.AddCommand(cleanCmd)
 	cleanCmd.Flags().BoolVarP(&cleanFlags.DryRun, "dry-run", "d", false, "conduct a dry run without modifying the Brewfile")
+	cleanCmd.Flags().BoolVarP(&cleanFlags.KeepComments, "keep-comments", "k", false, "keep comments and commented-out entries when rewriting the Brewfile")
+	cleanCmd.Flags().BoolVar(&cleanFlags.Installed, "installed", false, "resolve dependencies from the runtime dependencies recorded for installed brews")
 }
 
 // cleanCmd represents the clean command
@@ -25,14 +26,16 @@ var cleanCmd = &cobra.Command{
 	Long:  DocsClean,
 	Args:  cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		var packages brewfile.Packages
+		packages := brewfile.Packages{Layout: layout}
 
 		db, err := bolt.Open(boltPath, 0600, nil)
 		if err != nil {
 			errorExit(err)
 		}
 
-		cache := brew.Cache{DB: db}
+		cache := brew.Cache{DB: db, OnMiss: refreshIfStale}
+		cleanFlags.KeepComments = cleanFlags.KeepComments || viper.GetBool("keep_comments")
+		cleanFlags.Installed = cleanFlags.Installed || viper.GetBool("installed")
 
 		err = Clean(args, &packages, cache, brewfilePath, cleanFlags, level)
 		errorExit(err)
@@ -40,33 +43,49 @@ var cleanCmd = &cobra.Command{
 }
 
 func Clean(args []string, packages *brewfile.Packages, cache brew.Cache, brewfilePath string, flags Flags, level int) error {
+	packages.KeepComments = flags.KeepComments
+
 	if err := packages.FromBrewfile(brewfilePath); err != nil {
 		return err
 	}
 
-	cacheMap := brew.CacheMap{Cache: &cache, Map: make(brew.Map)}
+	cacheMap := brew.CacheMap{Cache: &cache, Map: make(brew.Map), Casks: make(brew.Map), Installed: flags.Installed}
 	if err := cacheMap.FromPackages(packages.Brew); err != nil {
 		return err
 	}
 
+	if err := cacheMap.FromCasks(packages.Cask); err != nil {
+		return err
+	}
+
+	for _, b := range packages.Brew {
+		if name := canonicalName(cache, b.Name); name != b.Name {
+			fmt.Printf("Renamed brew '%s' to '%s' in Brewfile.\n", b.Name, name)
+		}
+	}
+
 	if err := cacheMap.ResolveDependencyMap(level); err != nil {
 		return err
 	}
 
-	cleanBrews, err := cleanBrews(cacheMap)
+	cleanBrews, err := cleanBrews(cacheMap, packages.Layout)
 	if err != nil {
 		return err
 	}
 
 	packages.Brew = cleanBrews
 
+	cleanCasks, err := cleanCasks(cacheMap, packages.Layout)
+	if err != nil {
+		return err
+	}
+
+	packages.Cask = cleanCasks
+
 	if flags.DryRun {
-		b, err := packages.Bytes()
-		if err != nil {
+		if err := printPackages(packages); err != nil {
 			return err
 		}
-
-		fmt.Printf(string(b))
 	} else {
 		if err := writeToFile(brewfilePath, packages); err != nil {
 			return err
@@ -76,18 +95,40 @@ func Clean(args []string, packages *brewfile.Packages, cache brew.Cache, brewfil
 	return nil
 }
 
-func cleanBrews(cacheMap brew.CacheMap) ([]string, error) {
-	clean := []string{}
+func cleanBrews(cacheMap brew.CacheMap, layout *brewfile.Layout) (brewfile.Entries, error) {
+	clean := brewfile.Entries{}
 
 	for _, b := range cacheMap.Map {
-		entry, err := b.Format()
-		if err != nil {
-			return []string{}, err
+		for _, s := range b.Scopes() {
+			entry, err := s.BrewfileEntry(layout)
+			if err != nil {
+				return nil, err
+			}
+
+			clean = append(clean, entry)
 		}
+	}
 
-		clean = append(clean, entry)
+	clean.Sort()
+	return clean, nil
+}
+
+// Returns the casks in the cask map, annotated with the brews and casks requiring
+// them.
+func cleanCasks(cacheMap brew.CacheMap, layout *brewfile.Layout) (brewfile.Entries, error) {
+	clean := brewfile.Entries{}
+
+	for _, c := range cacheMap.Casks {
+		for _, s := range c.Scopes() {
+			entry, err := s.BrewfileEntry(layout)
+			if err != nil {
+				return nil, err
+			}
+
+			clean = append(clean, entry)
+		}
 	}
 
-	sort.Strings(clean)
+	clean.Sort()
 	return clean, nil
 }
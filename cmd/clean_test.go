@@ -3,10 +3,13 @@ package cmd_test
 import (
 	. "github.com/LGUG2Z/bfm/cmd"
 
+	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 
 	"io/ioutil"
+	"os/exec"
 
 	"github.com/LGUG2Z/bfm/brew"
 	"github.com/LGUG2Z/bfm/brewfile"
@@ -52,22 +55,95 @@ cask 'firefox'
 		It("Should read in the packages currently in the Brewfile", func() {
 			db.AddTestBrewsByName("a2ps")
 
-			expectedPackages := brewfile.Packages{
-				Tap:  []string{"tap 'homebrew/bundle'", "tap 'homebrew/core'"},
-				Brew: []string{"brew 'a2ps'"},
-				Cask: []string{"cask 'firefox'", "cask 'google-chrome'"},
-				Mas:  []string{"mas 'Xcode', id: 497799835"},
-			}
-
 			Expect(Clean([]string{}, &packages, cache, bf, Flags{DryRun: false}, 0)).To(Succeed())
 
-			Expect(packages).To(Equal(expectedPackages))
+			Expect(packages.Tap.Lines()).To(Equal([]string{"tap 'homebrew/bundle'", "tap 'homebrew/core'"}))
+			Expect(packages.Brew.Lines()).To(Equal([]string{"brew 'a2ps'"}))
+			Expect(packages.Cask.Lines()).To(Equal([]string{"cask 'firefox'", "cask 'google-chrome'"}))
+			Expect(packages.Mas.Lines()).To(Equal([]string{"mas 'Xcode', id: 497799835"}))
 		})
 
 		It("Should not proceed if a package in the Brewfile is not in the BoltDB cache", func() {
 			err := Clean([]string{}, &packages, cache, bf, Flags{DryRun: false}, 0)
 			Expect(err).To(HaveOccurred())
-			Expect(err.Error()).To(Equal(brew.ErrCouldNotFindPackageInfo("a2ps").Error()))
+			Expect(err.Error()).To(Equal(bf + ":3:1: " + brew.ErrCouldNotFindPackageInfo("a2ps").Error()))
+		})
+
+		It("Should report every package which is not in the BoltDB cache with its position", func() {
+			f := TestFile{Path: bf, Contents: "brew 'a2ps'\nbrew 'vim', args: :head\nbrew 'bash'\n"}
+			Expect(f.Create()).To(Succeed())
+			db.AddTestBrewsByName("vim")
+
+			err := Clean([]string{}, &packages, cache, bf, Flags{DryRun: false}, 0)
+			Expect(err).To(HaveOccurred())
+
+			lines := strings.Split(err.Error(), "\n")
+			Expect(lines).To(HaveLen(5))
+			Expect(lines[0]).To(Equal(bf + ":1:1: Could not find information for a2ps. Aborting."))
+			Expect(lines[1]).To(Equal(bf + ":2:19: expected a string or an array of strings, found :head"))
+			Expect(lines[2]).To(Equal(bf + ":3:1: Could not find information for bash. Aborting."))
+			Expect(lines[3]).To(HavePrefix("If this package is from a new tap"))
+		})
+
+		It("Should report every syntax error in the Brewfile with its position", func() {
+			f := TestFile{Path: bf, Contents: "brew 'a2ps',\ncask 'firefox'\nbrew 'vim', args: ['HEAD',\n  'with-lua' ]]\nmas 'Xcode', id: 497799835\n"}
+			Expect(f.Create()).To(Succeed())
+
+			err := Clean([]string{}, &packages, cache, bf, Flags{DryRun: false}, 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(bf + ":2:6: unexpected string \"'firefox'\", expected end of line\n" + bf + ":4:15: unexpected ']', expected end of line"))
+		})
+
+		It("Should rewrite brews listed under an alias or an old name to the current name of their formula", func() {
+			f := TestFile{Path: bf, Contents: "brew 'python3'\nbrew 'vim'\n"}
+			Expect(f.Create()).To(Succeed())
+			Expect(cache.Refresh(exec.Command("echo", `[ { "full_name": "python@3.9", "aliases": ["python3"] }, { "full_name": "vim" } ]`))).To(Succeed())
+
+			output := captureStdout(func() {
+				Expect(Clean([]string{}, &packages, cache, bf, Flags{}, brew.Required)).To(Succeed())
+			})
+			Expect(output).To(Equal("Renamed brew 'python3' to 'python@3.9' in Brewfile.\n"))
+
+			bytes, err := ioutil.ReadFile(bf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("brew 'python@3.9'\nbrew 'vim'\n"))
+		})
+
+		It("Should say which brews are renamed in a dry run too", func() {
+			f := TestFile{Path: bf, Contents: "brew 'python3'\n"}
+			Expect(f.Create()).To(Succeed())
+			Expect(cache.Refresh(exec.Command("echo", `[ { "full_name": "python@3.9", "aliases": ["python3"] } ]`))).To(Succeed())
+
+			output := captureStdout(func() {
+				Expect(Clean([]string{}, &packages, cache, bf, Flags{DryRun: true}, brew.Required)).To(Succeed())
+			})
+			Expect(output).To(Equal("Renamed brew 'python3' to 'python@3.9' in Brewfile.\nbrew 'python@3.9'\n"))
+		})
+
+		It("Should add the casks required by brews and casks to the cask section, annotated with what requires them", func() {
+			var wine brew.Info
+			Expect(json.Unmarshal([]byte(`{"full_name": "wine", "requirements": [{"name": "x11", "cask": "xquartz"}]}`), &wine)).To(Succeed())
+			Expect(db.AddTestBrewsFromInfo(wine, brew.Info{FullName: "openjdk"})).To(Succeed())
+
+			var tool brew.CaskInfo
+			tool.Token, tool.DependsOn.Formula = "tool", []string{"openjdk"}
+			Expect(db.AddTestCasksFromInfo(tool, brew.CaskInfo{Token: "xquartz"})).To(Succeed())
+
+			f := TestFile{Path: bf, Contents: "cask 'firefox'\ncask 'tool'\nbrew 'wine'\n"}
+			Expect(f.Create()).To(Succeed())
+
+			output := captureStdout(func() {
+				Expect(Clean([]string{}, &packages, cache, bf, Flags{DryRun: true}, brew.Required)).To(Succeed())
+			})
+
+			Expect(output).To(Equal(`brew 'wine'
+
+brew 'openjdk' # [required by: tool]
+
+cask 'firefox'
+cask 'tool'
+cask 'xquartz' # [required by: wine]
+`))
 		})
 
 		It("Should write out a new Brewfile in alphabetical order split into tap, brew, cask and mas sections", func() {
@@ -92,6 +168,45 @@ mas 'Xcode', id: 497799835
 			Expect(bytes).To(Equal([]byte(expectedContents)))
 		})
 
+		It("Should keep the interpolation of args written in double quotes", func() {
+			f = TestFile{Path: bf, Contents: "brew 'a2ps', args: [\"with-#{ENV['X']}\"]\n"}
+			Expect(f.Create()).To(Succeed())
+
+			db.AddTestBrewsByName("a2ps")
+
+			output := captureStdout(func() {
+				Expect(Clean([]string{}, &packages, cache, bf, Flags{DryRun: true}, 0)).To(Succeed())
+			})
+
+			Expect(output).To(Equal("brew 'a2ps', args: [\"with-#{ENV['X']}\"]\n"))
+		})
+
+		It("Should keep statements which are not entries, such as cask_args", func() {
+			f = TestFile{Path: bf, Contents: "brew 'a2ps'\ncask_args appdir: '~/Applications'\ncask 'firefox'\n"}
+			Expect(f.Create()).To(Succeed())
+
+			db.AddTestBrewsByName("a2ps")
+
+			Expect(Clean([]string{}, &packages, cache, bf, Flags{}, 0)).To(Succeed())
+
+			bytes, err := ioutil.ReadFile(bf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("cask_args appdir: '~/Applications'\n\nbrew 'a2ps'\n\ncask 'firefox'\n"))
+		})
+
+		It("Should keep a brew listed in more than one block in each of them", func() {
+			f = TestFile{Path: bf, Contents: "brew 'a2ps'\nif OS.mac?\n  brew 'a2ps'\n  brew 'gnu-sed'\nend\nif OS.linux?\n  brew 'gnu-sed'\nend\n"}
+			Expect(f.Create()).To(Succeed())
+
+			db.AddTestBrewsByName("a2ps", "gnu-sed")
+
+			output := captureStdout(func() {
+				Expect(Clean([]string{}, &packages, cache, bf, Flags{DryRun: true}, 0)).To(Succeed())
+			})
+
+			Expect(output).To(Equal("brew 'a2ps'\n\nif OS.mac?\n  brew 'a2ps'\n  brew 'gnu-sed'\nend\n\nif OS.linux?\n  brew 'gnu-sed'\nend\n"))
+		})
+
 		It("Should not modify the existing Brewfile if the --dry-run flag is set", func() {
 			db.AddTestBrewsByName("a2ps")
 
@@ -125,5 +240,64 @@ mas 'Xcode', id: 497799835
 
 			Expect(output).To(Equal(expectedOutput))
 		})
+
+		It("Should write the Brewfile with the configured layout, rewriting annotations in the configured style", func() {
+			db.AddTestBrewsFromInfo(
+				brew.Info{FullName: "a2ps", Dependencies: []string{"bash"}},
+				brew.Info{FullName: "bash"},
+			)
+
+			t := TestFile{Path: bf + ".layout", Contents: "cask 'firefox'\nbrew 'a2ps'\nbrew 'bash' # [required by: a2ps] login shell\n"}
+			Expect(t.Create()).To(Succeed())
+			defer t.Remove()
+
+			layout := brewfile.DefaultLayout()
+			layout.Sections = []string{"cask"}
+			layout.Headers = map[string]string{"dependent": "Dependencies"}
+			layout.Annotation = `{{ if .RequiredBy }}<- {{ StringsJoin .RequiredBy ", " }}{{ end }}`
+			Expect(layout.Validate()).To(Succeed())
+
+			output := captureStdout(func() {
+				Expect(Clean([]string{}, &brewfile.Packages{Layout: layout}, cache, t.Path, Flags{DryRun: true, KeepComments: true}, brew.Required)).To(Succeed())
+			})
+
+			Expect(output).To(Equal(`cask 'firefox'
+
+brew 'a2ps'
+
+# Dependencies
+brew 'bash' # <- a2ps login shell
+`))
+		})
+
+		It("Should keep comments and commented-out entries if the --keep-comments flag is set", func() {
+			db.AddTestBrewsFromInfo(
+				brew.Info{FullName: "a2ps", Dependencies: []string{"bash"}},
+				brew.Info{FullName: "bash"},
+			)
+
+			t := TestFile{Path: bf + ".comments", Contents: `# header
+
+# for printing
+brew 'a2ps'
+brew 'bash' # [required by: zsh] login shell
+# brew 'emacs'
+`}
+			Expect(t.Create()).To(Succeed())
+			defer t.Remove()
+
+			output := captureStdout(func() {
+				Expect(Clean([]string{}, &brewfile.Packages{}, cache, t.Path, Flags{DryRun: true, KeepComments: true}, brew.Required)).To(Succeed())
+			})
+
+			Expect(output).To(Equal(`# header
+
+# for printing
+brew 'a2ps'
+# brew 'emacs'
+
+brew 'bash' # [required by: a2ps] login shell
+`))
+		})
 	})
 })
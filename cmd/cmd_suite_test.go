@@ -141,6 +141,11 @@ func (db *TestDB) AddTestBrewsFromInfo(infos ...brew.Info) error {
 	return nil
 }
 
+func (db *TestDB) AddTestCasksFromInfo(infos ...brew.CaskInfo) error {
+	cache := brew.Cache{DB: db.DB}
+	return cache.PutCaskInfo(infos)
+}
+
 type TestFile struct {
 	Path, Contents string
 }
@@ -3,19 +3,39 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 var (
 	ErrEntryAlreadyExists          = func(name string) error { return fmt.Errorf("Entry for %s already exists in the Brewfile.", name) }
 	ErrEntryDoesNotExist           = func(name string) error { return fmt.Errorf("Entry for %s does not exist in the Brewfile.", name) }
 	ErrInvalidTapFormat            = errors.New("Invalid tap format. See bfm add --help.")
+	ErrInvalidWhalebrewFormat      = errors.New("Invalid whalebrew image format. See bfm add --help.")
+	ErrInvalidVscodeFormat         = errors.New("Invalid VS Code extension format. See bfm add --help.")
 	ErrInvalidRestartServiceOption = errors.New("Invalid --restart-service option. See bfm add --help")
+	ErrInvalidLinkOption           = errors.New("Invalid --link option. See bfm add --help")
+	ErrInvalidLevelOption          = errors.New("Invalid --level option. See bfm add --help")
 	ErrDependencyLevelNotSet       = errors.New("BFM_LEVEL not set in shell rc file. See bfm --help.")
 	ErrBrewfileNotSet              = errors.New("BFM_BREWFILE not set in shell rc file. See bfm --help.")
 
+	ErrAlreadyExplicit = func(name string) error {
+		return fmt.Errorf("Entry for %s is already explicit in the Brewfile.", name)
+	}
+	ErrNotExplicit = func(name string) error {
+		return fmt.Errorf("Entry for %s is not explicit in the Brewfile.", name)
+	}
+	ErrTapInUse = func(tap string, packages []string) error {
+		return fmt.Errorf("Tap %s is still needed by %s. Remove them first or use --cascade.", tap, strings.Join(packages, ", "))
+	}
+	ErrInvalidCacheTTL = func(ttl string) error {
+		return fmt.Errorf("Invalid BFM_CACHE_TTL %s. Use a duration such as 72h, or 0 for a cache which never expires.", ttl)
+	}
 	ErrNoPackageType = func(command string) error {
 		return fmt.Errorf("No package type specified. See bfm %s --help.", command)
 	}
+	ErrUnknownGraphFormat = func(format string) error {
+		return fmt.Errorf("Unknown graph format %s. Use dot, mermaid or json.", format)
+	}
 	ErrNoMasID = func(name string) error {
 		return fmt.Errorf("An ID is required for mas entries. Run 'mas search %s' to get the ID.", name)
 	}
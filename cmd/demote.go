@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"github.com/LGUG2Z/bfm/brew"
+	"github.com/LGUG2Z/bfm/brewfile"
+	"github.com/boltdb/bolt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var demoteFlags Flags
+
+func init() {
+	RootCmd.AddCommand(demoteCmd)
+
+	demoteCmd.Flags().BoolVarP(&demoteFlags.DryRun, "dry-run", "d", false, "conduct a dry run without modifying the Brewfile")
+	demoteCmd.Flags().BoolVarP(&demoteFlags.KeepComments, "keep-comments", "k", false, "keep comments and commented-out entries when rewriting the Brewfile")
+
+	demoteCmd.Flags().BoolVarP(&demoteFlags.Brew, "brew", "b", false, "demote a brew package")
+	demoteCmd.Flags().BoolVarP(&demoteFlags.Cask, "cask", "c", false, "demote a cask")
+}
+
+// demoteCmd represents the demote command
+var demoteCmd = &cobra.Command{
+	Use:   "demote",
+	Short: "Stop marking a dependency in your Brewfile as explicit",
+	Long:  DocsDemote,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		packages := brewfile.Packages{Layout: layout}
+
+		db, err := bolt.Open(boltPath, 0600, nil)
+		if err != nil {
+			errorExit(err)
+		}
+
+		cache := brew.Cache{DB: db, OnMiss: refreshIfStale}
+		demoteFlags.KeepComments = demoteFlags.KeepComments || viper.GetBool("keep_comments")
+
+		err = Demote(args, &packages, cache, brewfilePath, demoteFlags, level)
+		errorExit(err)
+	},
+}
+
+func Demote(args []string, packages *brewfile.Packages, cache brew.Cache, brewfilePath string, flags Flags, level int) error {
+	return setExplicit(args[0], false, packages, cache, brewfilePath, flags)
+}
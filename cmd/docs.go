@@ -12,6 +12,20 @@ first need to be exported in your shell rc file:
 BFM_BREWFILE=/path/to/your/Brewfile
 BFM_LEVEL=[required, recommended, optional, build]
 
+Optionally, BFM_KEEP_COMMENTS=true makes every command that
+rewrites the Brewfile behave as if --keep-comments was given.
+BFM_INSTALLED=true makes every command behave as if
+--installed was given, resolving the dependencies of installed
+brews from the runtime dependencies Homebrew recorded when
+installing them instead of from their formulae.
+
+BFM_CACHE_TTL sets how long the cache stays fresh, as a
+duration such as 72h, a week by default, or 0 to never expire.
+When a brew cannot be found in a cache which has expired, or
+with taps tapped, untapped or updated since it was refreshed,
+BFM_AUTO_REFRESH=true refreshes the cache and looks the brew up
+again, and a warning is printed otherwise.
+
 When adding a new package to a Brewfile whitelist, it is
 not uncommon for that package to install other packages
 which are required dependencies, and depending on the
@@ -26,7 +40,10 @@ have been added for the cleanup command to remain useful.
 
 By setting a BFM_LEVEL, when performing any operation with
 bfm, the level of dependencies to operate on can be kept
-consistent.
+consistent. Each level includes the dependency types before
+it; to pick dependency types individually, list them instead,
+such as BFM_LEVEL=required,build for required and build
+dependencies without recommended or optional ones.
 
 When you add and remove packages using bfm, depending on the
 level chosen, all of the required, recommended, optional or
@@ -47,22 +64,70 @@ The type must be specified using the appropriate flag.
 
 Taps must conform to the format <user/repo>.
 
+Whalebrew images must conform to the format <user/image> and
+VS Code extensions to the format <publisher.extension>.
+
 Brew packages can have arguments specified using the --args
 flag (multiple arguments can be separated by using a comma),
 and can specify service restart behaviour ('always' to
 restart every time bundle is run, 'changed' to restart only
 when updated or changed) with the --restart-service flag.
 
+The args of a brew are taken into account when resolving its
+dependencies: optional dependencies enabled with a with-* arg
+are added at any level, recommended dependencies disabled with
+a without-* arg are left out, and the build dependencies of a
+brew built with HEAD or build-from-source are added at any
+level.
+
+The --level flag sets the dependency level of a single brew,
+overriding BFM_LEVEL for that brew when adding, removing or
+cleaning. It is kept in the Brewfile as a '[level: build]'
+marker in the comment of the brew, which can also be written
+by hand.
+
+The remaining brew options of Homebrew Bundle can be set with
+the --link (true, false or overwrite), --conflicts-with
+(multiple brews can be separated by using a comma),
+--start-service and --postinstall flags.
+
+A brew is not added if it or any of its dependencies conflicts
+with a brew in the Brewfile. The conflicting pair is reported
+with the dependencies bringing in each brew, unless one of the
+brews lists the other in its conflicts_with option.
+
 MAS apps must specify an id using the --mas-id flag which
 can be found by running 'mas search <app>'.
 
+Brews and casks from taps other than the ones Homebrew comes
+with, such as crisidev/chunkwm/chunkwm, are added along with
+their tap, in the same block and Brewfile as the brew or cask,
+unless the tap is already in the Brewfile.
+
+Entries of any type can be added to a conditional block with
+the --when flag, using 'mac' for 'if OS.mac?', 'linux' for
+'if OS.linux?' or any other Ruby condition. Dependencies of a
+brew added to a block are scoped to the same block unless
+they are also needed outside of it.
+
+Brewfiles included with instance_eval(File.read("...")) are
+read and written along with the main Brewfile. Entries can be
+added to an included Brewfile with the --file flag, and are
+otherwise added to the main Brewfile.
+
 Examples:
 
 bfm add -t homebrew/dupes
 bfm add -b vim --args HEAD,with-override-system-vi
 bfm add -b crisidev/chunkwm/chunkwm --restart-service changed
+bfm add -b mysql@5.7 --link true --conflicts-with mysql --start-service
+bfm add -b vim --args HEAD --level build
 bfm add -c macvim
 bfm add -m Xcode -i 497799835
+bfm add -w whalebrew/wget
+bfm add -v golang.go
+bfm add -c iterm2 --when mac
+bfm add -c slack --file Brewfile.work
 
 `
 	DocsCheck = `
@@ -72,7 +137,8 @@ Brewfile.
 If the arguments corresponds to a brew entry in the Brewfile,
 the check command will provide information about both any
 dependencies it has, or any other entries of which it is
-itself a dependency.
+itself a dependency, followed by any brews in the Brewfile it
+conflicts with.
 
 The type must be specified using the appropriate flag.
 
@@ -82,12 +148,40 @@ bfm check -t homebrew/dupes
 bfm check -b vim
 bfm check -c macvim
 bfm check -m Xcode
+bfm check -v golang.go
 
 `
 	DocsClean = `
 Cleans up your Brewfile, removing all comments and sorting
 all dependencies into alphabetised groups with the order tap
--> brew (primary) -> brew (dependent) -> cask -> mas.
+-> brew (primary) -> brew (dependent) -> cask -> mas ->
+whalebrew -> vscode. Entries inside conditional blocks such as
+'if OS.mac?' stay in their block, which is sorted in the same
+way and written after the unconditional entries. Other
+statements, such as cask_args, are kept as written at the top
+of their block.
+
+With the --keep-comments flag, comments are kept instead: a
+comment block at the top of the file followed by a blank line
+stays at the top, comment lines above an entry and comments at
+the end of an entry line move with the entry, and commented-out
+entries such as "# brew 'emacs'" are sorted into their section.
+The dependency annotations generated by bfm are regenerated.
+
+Casks take part in dependency resolution too: the brews and
+casks a cask depends on, and the casks meeting the
+requirements of a formula, such as xquartz for formulae
+requiring X11, are added and annotated like dependent brews,
+with casks staying in the cask section.
+
+Brews listed under an alias or an old name of their formula,
+such as 'python3', are rewritten under the current name of
+the formula, with a notice for each brew renamed.
+
+The section order, section header comments, blank lines
+between sections, quote style and annotation template can be
+configured in the layout section of the config file. See the
+README for details.
 
 This command will modify your Brewfile without creating a
 backup. Consider running the command with the --dry-run flag
@@ -97,6 +191,123 @@ Examples:
 
 bfm clean
 bfm clean --dry-run
+bfm clean --keep-comments
+
+`
+	DocsLint = `
+Checks the dependency annotations generated by bfm in your
+Brewfile against the dependencies in the cache, at the
+dependency level set by BFM_LEVEL.
+
+Annotations such as '[required by: neovim]' which list other
+brews than the ones depending on a brew are reported as stale,
+and annotations which list the right brews but differ from
+what bfm would write are reported as edited by hand. Brews
+which other brews depend on but which are not annotated, and
+annotated brews which no other brew depends on, are reported
+too, as are pairs of brews which conflict with each other and
+are not acknowledged with the conflicts_with option. Every
+problem is reported with its position in the Brewfile.
+
+Comments written by hand next to an annotation are not
+checked. Running 'bfm clean' regenerates the annotations.
+
+Examples:
+
+bfm lint
+
+`
+	DocsGraph = `
+Exports the dependency graph of the brews in your Brewfile,
+at the dependency level set by BFM_LEVEL, for rendering.
+
+Every brew is a node, marked as primary if no other brew
+depends on it, with the tap it comes from. Every dependency
+is an edge labelled with its type: required, recommended,
+optional or build.
+
+The graph is written in the DOT language of Graphviz by
+default, or as a Mermaid flowchart or JSON with the --format
+flag. Given a brew, only that brew and its dependencies are
+exported, and the --depth flag limits the graph to the brews
+at most that many dependencies away from the primary brews or
+the brew given.
+
+Examples:
+
+bfm graph | dot -Tsvg > Brewfile.svg
+bfm graph neovim --format mermaid
+bfm graph --format json --depth 1
+
+`
+	DocsTree = `
+Shows the brews in your Brewfile as a tree, with every
+primary brew at the top and the dependencies of each brew
+nested beneath it, marked with their type: required,
+recommended, optional or build. Dependencies are resolved
+the same way as by the clean command, at the dependency level
+set by BFM_LEVEL.
+
+The dependencies of a brew are only shown the first time the
+brew appears in the tree, and marked "(see above)" after that.
+
+With the --reverse flag, the tree starts from the brews which
+have no dependencies of their own and shows the brews
+depending on each brew beneath it instead. Given a brew, only
+the tree starting from that brew is shown.
+
+Examples:
+
+bfm tree
+bfm tree neovim
+bfm tree gettext --reverse
+
+`
+	DocsWhy = `
+Explains why a brew is in your Brewfile, printing every chain
+of dependencies leading from a primary brew, which no other
+brew depends on, down to the brew given, with the type of
+dependency of each brew along the chain.
+
+Dependencies are resolved at the dependency level set by
+BFM_LEVEL.
+
+Examples:
+
+bfm why gettext
+bfm why python@3.9
+
+`
+	DocsPromote = `
+Marks a brew or cask in the Brewfile as explicit, for packages
+whitelisted on purpose which are also dependencies of other
+packages.
+
+Explicit brews stay in the primary brews section, keeping the
+annotations listing the brews depending on them, and are not
+removed along with the last package depending on them. The
+entry is marked with an '[explicit]' marker in its comment,
+which can also be written by hand.
+
+The type must be specified using the appropriate flag.
+
+Examples:
+
+bfm promote -b openssl
+bfm promote -c java
+
+`
+	DocsDemote = `
+Stops marking a brew or cask in the Brewfile as explicit, so
+that it is treated as a dependency again: an annotated brew
+moves to the dependent brews section, and is removed along with
+the last package depending on it.
+
+The type must be specified using the appropriate flag.
+
+Examples:
+
+bfm demote -b openssl
 
 `
 	DocsRefresh = `
@@ -109,6 +320,16 @@ home folder.
 
 This command should be run after adding a new tap.
 
+The info of every cask, including the brews and casks it
+depends on, is stored too. The aliases and old names of every
+formula are indexed as well, so brews can be found by any of
+the names Homebrew knows them by.
+
+The cache records its schema version, the time of the refresh,
+the version of Homebrew and the commit of every installed tap.
+A cache built by an older version of bfm is upgraded when bfm
+starts, or rebuilt if it cannot be upgraded in place.
+
 Examples:
 
 bfm refresh
@@ -124,12 +345,21 @@ flag if using bfm for the first time.
 
 The type must be specified using the appropriate flag.
 
+Taps left behind by removing the last brew or cask coming from
+them are removed too, unless the --keep-tap flag is given. A
+tap which brews or casks in the Brewfile still come from is not
+removed, unless the --cascade flag is given to remove them along
+with it.
+
 Examples:
 
 bfm remove -t homebrew/dupes
+bfm remove -t crisidev/chunkwm --cascade
+bfm remove -b crisidev/chunkwm/chunkwm --keep-tap
 bfm remove -b vim
 bfm remove -c macvim
 bfm remove -m Xcode
+bfm remove -w whalebrew/wget
 
 `
 )
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/LGUG2Z/bfm/brew"
+	"github.com/LGUG2Z/bfm/brewfile"
+	"github.com/boltdb/bolt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var graphFlags Flags
+
+func init() {
+	RootCmd.AddCommand(graphCmd)
+
+	graphCmd.Flags().StringVarP(&graphFlags.Format, "format", "f", "dot", "output format: dot, mermaid or json")
+	graphCmd.Flags().IntVar(&graphFlags.Depth, "depth", 0, "only include packages up to this many dependencies away (0 for no limit)")
+	graphCmd.Flags().BoolVar(&graphFlags.Installed, "installed", false, "resolve dependencies from the runtime dependencies recorded for installed brews")
+}
+
+// graphCmd represents the graph command
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Export the dependency graph of your Brewfile",
+	Long:  DocsGraph,
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		packages := brewfile.Packages{Layout: layout}
+
+		db, err := bolt.Open(boltPath, 0600, nil)
+		if err != nil {
+			errorExit(err)
+		}
+
+		cache := brew.Cache{DB: db, OnMiss: refreshIfStale}
+		graphFlags.Installed = graphFlags.Installed || viper.GetBool("installed")
+
+		err = Graph(args, &packages, cache, brewfilePath, graphFlags, level)
+		errorExit(err)
+	},
+}
+
+func Graph(args []string, packages *brewfile.Packages, cache brew.Cache, brewfilePath string, flags Flags, level int) error {
+	switch flags.Format {
+	case "dot", "mermaid", "json":
+	default:
+		return ErrUnknownGraphFormat(flags.Format)
+	}
+
+	if err := packages.FromBrewfile(brewfilePath); err != nil {
+		return err
+	}
+
+	cacheMap := brew.CacheMap{Cache: &cache, Map: make(brew.Map), Casks: make(brew.Map), Installed: flags.Installed}
+	if err := cacheMap.FromPackages(packages.Brew); err != nil {
+		return err
+	}
+
+	if err := cacheMap.FromCasks(packages.Cask); err != nil {
+		return err
+	}
+
+	if err := cacheMap.ResolveDependencyMap(level); err != nil {
+		return err
+	}
+
+	var root string
+	if len(args) > 0 {
+		name, err := fullName(cacheMap, args[0])
+		if err != nil {
+			return err
+		}
+
+		if _, present := cacheMap.Lookup(name); !present {
+			return ErrEntryDoesNotExist(name)
+		}
+
+		root = name
+	}
+
+	export := cacheMap.Export(root, flags.Depth)
+
+	var output []byte
+	switch flags.Format {
+	case "dot":
+		output = export.DOT()
+	case "mermaid":
+		output = export.Mermaid()
+	case "json":
+		b, err := export.JSON()
+		if err != nil {
+			return err
+		}
+
+		output = b
+	}
+
+	fmt.Print(string(output))
+	return nil
+}
@@ -0,0 +1,84 @@
+package cmd_test
+
+import (
+	. "github.com/LGUG2Z/bfm/cmd"
+
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/LGUG2Z/bfm/brew"
+	"github.com/LGUG2Z/bfm/brewfile"
+	"github.com/LGUG2Z/bfm/graph"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Graph", func() {
+	var (
+		bf     = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "src/github.com/LGUG2Z/bfm/testData/testBrewfile")
+		dbFile = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "src/github.com/LGUG2Z/bfm/testData/testDB.bolt")
+		cache  brew.Cache
+		f      TestFile
+		db     *TestDB
+	)
+
+	BeforeEach(func() {
+		f = TestFile{Path: bf, Contents: "brew 'neovim'\nbrew 'gettext' # [required by: neovim]\n"}
+		Expect(f.Create()).To(Succeed())
+
+		testDB, err := NewTestDB(dbFile)
+		db = testDB
+		Expect(err).ToNot(HaveOccurred())
+		cache.DB = db.DB
+
+		Expect(db.AddTestBrewsFromInfo(
+			brew.Info{FullName: "neovim", Dependencies: []string{"gettext"}},
+			brew.Info{FullName: "gettext"},
+		)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		f.Remove()
+		db.Close()
+	})
+
+	Describe("When the command is called", func() {
+		It("Should print the dependency graph in the format given", func() {
+			output := captureStdout(func() {
+				Expect(Graph([]string{}, &brewfile.Packages{}, cache, bf, Flags{Format: "mermaid"}, brew.Required)).To(Succeed())
+			})
+
+			Expect(output).To(Equal("graph TD\n\tn0(\"gettext\")\n\tn1[\"neovim\"]\n\tn1 -->|required| n0\n"))
+		})
+
+		It("Should include casks, and the casks required by brews, as cask nodes", func() {
+			var wine brew.Info
+			Expect(json.Unmarshal([]byte(`{"full_name": "wine", "requirements": [{"name": "x11", "cask": "xquartz"}]}`), &wine)).To(Succeed())
+			Expect(db.AddTestBrewsFromInfo(wine)).To(Succeed())
+			Expect(db.AddTestCasksFromInfo(brew.CaskInfo{Token: "firefox"}, brew.CaskInfo{Token: "xquartz"})).To(Succeed())
+
+			f := TestFile{Path: bf, Contents: "brew 'wine'\ncask 'firefox'\n"}
+			Expect(f.Create()).To(Succeed())
+
+			output := captureStdout(func() {
+				Expect(Graph([]string{}, &brewfile.Packages{}, cache, bf, Flags{Format: "json"}, brew.Required)).To(Succeed())
+			})
+
+			var export struct{ Nodes []graph.Node }
+			Expect(json.Unmarshal([]byte(output), &export)).To(Succeed())
+			Expect(export.Nodes).To(ConsistOf(
+				graph.Node{Name: "firefox", Tap: "homebrew/cask", Type: "cask", Primary: true},
+				graph.Node{Name: "wine", Tap: "homebrew/core", Type: "brew", Primary: true},
+				graph.Node{Name: "xquartz", Tap: "homebrew/cask", Type: "cask"},
+			))
+		})
+
+		It("Should return an error for unknown formats and packages not in the Brewfile", func() {
+			Expect(Graph([]string{}, &brewfile.Packages{}, cache, bf, Flags{Format: "svg"}, brew.Required)).To(Equal(ErrUnknownGraphFormat("svg")))
+
+			Expect(db.AddTestBrewsByName("vim")).To(Succeed())
+			Expect(Graph([]string{"vim"}, &brewfile.Packages{}, cache, bf, Flags{Format: "dot"}, brew.Required)).To(Equal(ErrEntryDoesNotExist("vim")))
+		})
+	})
+})
@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"strings"
+	"path/filepath"
 
+	"github.com/LGUG2Z/bfm/brew"
 	"github.com/LGUG2Z/bfm/brewfile"
+	"github.com/LGUG2Z/bfm/helpers"
+	"github.com/boltdb/bolt"
 )
 
 func errorExit(err error) {
@@ -17,7 +20,7 @@ func errorExit(err error) {
 }
 
 func flagProvided(flags Flags) bool {
-	return flags.Tap || flags.Brew || flags.Cask || flags.Mas
+	return flags.Tap || flags.Brew || flags.Cask || flags.Mas || flags.Whalebrew || flags.Vscode
 }
 
 func getPackageType(flags Flags) string {
@@ -37,43 +40,128 @@ func getPackageType(flags Flags) string {
 		return "mas"
 	}
 
+	if flags.Whalebrew {
+		return "whalebrew"
+	}
+
+	if flags.Vscode {
+		return "vscode"
+	}
+
 	return ""
 }
 
-func constructBaseEntry(packageType, packageName string) string {
-	return fmt.Sprintf("%s '%s'", packageType, packageName)
+// Writes the Brewfile and every Brewfile it includes.
+func writeToFile(path string, packages *brewfile.Packages) error {
+	for _, file := range packages.Files() {
+		b, err := packages.FileBytes(file)
+		if err != nil {
+			return err
+		}
+
+		if file == packages.Path {
+			file = path
+		}
+
+		if err := ioutil.WriteFile(file, b, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func entryExists(contents, packageType, packageToCheck string) bool {
-	packageEntry := constructBaseEntry(packageType, packageToCheck)
+// Prints the Brewfile, followed by every Brewfile it includes under a header
+// with its path.
+func printPackages(packages *brewfile.Packages) error {
+	for i, file := range packages.Files() {
+		b, err := packages.FileBytes(file)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			fmt.Printf("\n==> %s <==\n", file)
+		}
 
-	if !strings.Contains(contents, packageEntry) {
-		return false
+		fmt.Print(string(b))
 	}
 
-	return true
+	return nil
 }
 
-func getPackages(packageType string, lines []string) []string {
-	var packages []string
-	for _, line := range lines {
-		if strings.HasPrefix(line, packageType) {
-			packages = append(packages, line)
+// Returns the path of the included Brewfile given with the --file flag, or an
+// empty path for the Brewfile itself. Paths are relative to the working
+// directory or to the directory of the Brewfile.
+func includedFile(packages *brewfile.Packages, file string) (string, error) {
+	if len(file) < 1 {
+		return "", nil
+	}
+
+	candidates := []string{filepath.Clean(file), filepath.Join(filepath.Dir(packages.Path), file)}
+
+	for i, path := range packages.Files() {
+		if helpers.Contains(candidates, filepath.Clean(path)) {
+			if i == 0 {
+				return "", nil
+			}
+
+			return path, nil
 		}
 	}
 
-	return packages
+	return "", brewfile.ErrNotIncluded(file)
 }
 
-func writeToFile(path string, packages *brewfile.Packages) error {
-	b, err := packages.Bytes()
+// Returns the name a brew is kept under in a CacheMap, which is its full name,
+// looking the full name up in the cache if neither a brew nor a cask is in the
+// CacheMap under the name given.
+func fullName(cacheMap brew.CacheMap, name string) (string, error) {
+	if _, present := cacheMap.Lookup(name); present {
+		return name, nil
+	}
+
+	info, err := cacheMap.Cache.Find(name)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	if err := ioutil.WriteFile(path, b, 0644); err != nil {
-		return err
+	return info.FullName, nil
+}
+
+// Opens the cache at the given path to bring it up to the current schema
+// version, reporting whether it was emptied and has to be refreshed.
+func migrateCache(path string) (bool, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return false, err
 	}
 
-	return nil
+	defer db.Close()
+
+	cache := brew.Cache{DB: db}
+	return cache.Migrate()
+}
+
+// Reports whether the given tap is among the taps of a Brewfile.
+func hasTap(taps brewfile.Entries, t string) bool {
+	for _, e := range taps {
+		if brew.SameTap(e.Name, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the full name of the formula of a brew, which differs from the name
+// given for brews given by an alias or an old name, or the name given if the
+// formula cannot be found.
+func canonicalName(cache brew.Cache, name string) string {
+	info, err := cache.Find(name)
+	if err != nil {
+		return name
+	}
+
+	return info.FullName
 }
@@ -1,28 +1,24 @@
 package cmd
 
 import (
-	"reflect"
 	"testing"
 )
 
-func TestConstructBaseEntry(t *testing.T) {
-	packageType := "brew"
-	packageName := "bfm"
+func TestGetPackageType(t *testing.T) {
+	flags := Flags{Cask: true}
 
-	actual := constructBaseEntry(packageType, packageName)
-	expected := "brew 'bfm'"
+	actual := getPackageType(flags)
+	expected := "cask"
 
 	if actual != expected {
 		t.Fatalf("Expected %s but got %s", expected, actual)
 	}
 }
 
-func TestEntryExistsTrue(t *testing.T) {
-	contents := "brew 'vim'\nbrew 'neovim'"
-	packageType := "brew"
-	packageName := "neovim"
+func TestFlagProvidedTrue(t *testing.T) {
+	flags := Flags{Mas: true}
 
-	actual := entryExists(contents, packageType, packageName)
+	actual := flagProvided(flags)
 	expected := true
 
 	if actual != expected {
@@ -30,26 +26,13 @@ func TestEntryExistsTrue(t *testing.T) {
 	}
 }
 
-func TestEntryExistsFalse(t *testing.T) {
-	contents := "brew 'vim'\nbrew 'neovim'"
-	packageType := "cask"
-	packageName := "macvim"
+func TestFlagProvidedFalse(t *testing.T) {
+	flags := Flags{DryRun: true}
 
-	actual := entryExists(contents, packageType, packageName)
+	actual := flagProvided(flags)
 	expected := false
 
 	if actual != expected {
 		t.Fatalf("Expected %t but got %t", expected, actual)
 	}
 }
-
-func TestGetPackages(t *testing.T) {
-	lines := []string{"tap 'homebrew/bundle'", "brew 'vim'", "cask 'macvim'"}
-
-	expected := []string{"brew 'vim'"}
-	actual := getPackages("brew", lines)
-
-	if !reflect.DeepEqual(actual, expected) {
-		t.Fatalf("Expected %s but got %s", expected, actual)
-	}
-}
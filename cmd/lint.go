@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/LGUG2Z/bfm/brew"
+	"github.com/LGUG2Z/bfm/brewfile"
+	"github.com/LGUG2Z/bfm/diagnostics"
+	"github.com/boltdb/bolt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var lintFlags Flags
+
+func init() {
+	RootCmd.AddCommand(lintCmd)
+
+	lintCmd.Flags().BoolVar(&lintFlags.Installed, "installed", false, "resolve dependencies from the runtime dependencies recorded for installed brews")
+}
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check the annotations in your Brewfile",
+	Long:  DocsLint,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		packages := brewfile.Packages{Layout: layout}
+
+		db, err := bolt.Open(boltPath, 0600, nil)
+		if err != nil {
+			errorExit(err)
+		}
+
+		cache := brew.Cache{DB: db, OnMiss: refreshIfStale}
+		lintFlags.Installed = lintFlags.Installed || viper.GetBool("installed")
+
+		err = Lint(args, &packages, cache, brewfilePath, lintFlags, level)
+		errorExit(err)
+	},
+}
+
+func Lint(args []string, packages *brewfile.Packages, cache brew.Cache, brewfilePath string, flags Flags, level int) error {
+	if err := packages.FromBrewfile(brewfilePath); err != nil {
+		return err
+	}
+
+	cacheMap := brew.CacheMap{Cache: &cache, Map: make(brew.Map), Casks: make(brew.Map), Installed: flags.Installed}
+	if err := cacheMap.FromPackages(packages.Brew); err != nil {
+		return err
+	}
+
+	if err := cacheMap.FromCasks(packages.Cask); err != nil {
+		return err
+	}
+
+	if err := cacheMap.ResolveDependencyMap(level); err != nil {
+		return err
+	}
+
+	entries := append(append(brewfile.Entries{}, packages.Brew...), packages.Cask...)
+	var errs diagnostics.List
+	errs.Add(diagnostics.Position{}, cacheMap.CheckAnnotations(entries, packages.Layout))
+	errs.Add(diagnostics.Position{}, cacheMap.CheckConflicts(nil))
+	if err := errs.Err(); err != nil {
+		return err
+	}
+
+	fmt.Println("No problems found in the Brewfile.")
+	return nil
+}
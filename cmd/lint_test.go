@@ -0,0 +1,90 @@
+package cmd_test
+
+import (
+	. "github.com/LGUG2Z/bfm/cmd"
+
+	"fmt"
+	"os"
+
+	"github.com/LGUG2Z/bfm/brew"
+	"github.com/LGUG2Z/bfm/brewfile"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lint", func() {
+	var (
+		bf     = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "src/github.com/LGUG2Z/bfm/testData/testBrewfile")
+		dbFile = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "src/github.com/LGUG2Z/bfm/testData/testDB.bolt")
+		cache  brew.Cache
+		f      TestFile
+		db     *TestDB
+	)
+
+	BeforeEach(func() {
+		testDB, err := NewTestDB(dbFile)
+		db = testDB
+		Expect(err).ToNot(HaveOccurred())
+		cache.DB = db.DB
+
+		Expect(db.AddTestBrewsFromInfo(
+			brew.Info{FullName: "neovim", Dependencies: []string{"gettext", "libuv", "lua"}},
+			brew.Info{FullName: "weechat", Dependencies: []string{"gettext"}},
+			brew.Info{FullName: "gettext"},
+			brew.Info{FullName: "libuv"},
+			brew.Info{FullName: "lua"},
+			brew.Info{FullName: "jq"},
+			brew.Info{FullName: "mariadb", ConflictsWith: []string{"mysql"}},
+			brew.Info{FullName: "mysql", ConflictsWith: []string{"mariadb"}},
+		)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		f.Remove()
+		db.Close()
+	})
+
+	Describe("When the command is called", func() {
+		It("Should report nothing if the annotations match the dependencies", func() {
+			f = TestFile{Path: bf, Contents: "brew 'neovim'\nbrew 'weechat' # chat\nbrew 'gettext' # [required by: neovim, weechat] keep\nbrew 'libuv' # [required by: neovim]\nbrew 'lua' # [required by: neovim]\n"}
+			Expect(f.Create()).To(Succeed())
+
+			output := captureStdout(func() {
+				Expect(Lint([]string{}, &brewfile.Packages{}, cache, bf, Flags{}, brew.Required)).To(Succeed())
+			})
+
+			Expect(output).To(Equal("No problems found in the Brewfile.\n"))
+		})
+
+		It("Should report stale, hand-edited and missing annotations with their positions", func() {
+			f = TestFile{Path: bf, Contents: `brew 'neovim'
+brew 'weechat'
+brew 'gettext' # [required by: weechat, neovim]
+brew 'libuv' # [required by: neovim, weechat]
+brew 'lua'
+brew 'jq' # [required by: neovim] json
+`}
+			Expect(f.Create()).To(Succeed())
+
+			err := Lint([]string{}, &brewfile.Packages{}, cache, bf, Flags{}, brew.Required)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(
+				bf + ":3:1: The annotation of gettext has been edited by hand: expected [required by: neovim, weechat], found [required by: weechat, neovim].\n" +
+					bf + ":4:1: The annotation of libuv is stale: expected [required by: neovim], found [required by: neovim, weechat].\n" +
+					bf + ":5:1: lua is a dependency of other brews but is not annotated: expected [required by: neovim].\n" +
+					bf + ":6:1: jq is annotated with [required by: neovim] but no brew in the Brewfile depends on it.\n" +
+					"Run 'bfm clean' to regenerate the annotations."))
+		})
+
+		It("Should report conflicting brews with their positions", func() {
+			f = TestFile{Path: bf, Contents: "brew 'mariadb'\nbrew 'mysql'\n"}
+			Expect(f.Create()).To(Succeed())
+
+			err := Lint([]string{}, &brewfile.Packages{}, cache, bf, Flags{}, brew.Required)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(
+				bf + ":1:1: mariadb conflicts with mysql.\n  mariadb is a primary brew.\n  mysql is a primary brew.\n" +
+					"Remove one of the conflicting brews, or list the other in the conflicts_with option of one of them."))
+		})
+	})
+})
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/LGUG2Z/bfm/brew"
+	"github.com/LGUG2Z/bfm/brewfile"
+	"github.com/boltdb/bolt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var promoteFlags Flags
+
+func init() {
+	RootCmd.AddCommand(promoteCmd)
+
+	promoteCmd.Flags().BoolVarP(&promoteFlags.DryRun, "dry-run", "d", false, "conduct a dry run without modifying the Brewfile")
+	promoteCmd.Flags().BoolVarP(&promoteFlags.KeepComments, "keep-comments", "k", false, "keep comments and commented-out entries when rewriting the Brewfile")
+
+	promoteCmd.Flags().BoolVarP(&promoteFlags.Brew, "brew", "b", false, "promote a brew package")
+	promoteCmd.Flags().BoolVarP(&promoteFlags.Cask, "cask", "c", false, "promote a cask")
+}
+
+// promoteCmd represents the promote command
+var promoteCmd = &cobra.Command{
+	Use:   "promote",
+	Short: "Mark a dependency in your Brewfile as explicit",
+	Long:  DocsPromote,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		packages := brewfile.Packages{Layout: layout}
+
+		db, err := bolt.Open(boltPath, 0600, nil)
+		if err != nil {
+			errorExit(err)
+		}
+
+		cache := brew.Cache{DB: db, OnMiss: refreshIfStale}
+		promoteFlags.KeepComments = promoteFlags.KeepComments || viper.GetBool("keep_comments")
+
+		err = Promote(args, &packages, cache, brewfilePath, promoteFlags, level)
+		errorExit(err)
+	},
+}
+
+func Promote(args []string, packages *brewfile.Packages, cache brew.Cache, brewfilePath string, flags Flags, level int) error {
+	return setExplicit(args[0], true, packages, cache, brewfilePath, flags)
+}
+
+// Marks a brew or cask in the Brewfile as explicit, or no longer explicit, and
+// writes the Brewfile back out.
+func setExplicit(name string, explicit bool, packages *brewfile.Packages, cache brew.Cache, brewfilePath string, flags Flags) error {
+	command := "promote"
+	if !explicit {
+		command = "demote"
+	}
+
+	if !flags.Brew && !flags.Cask {
+		return ErrNoPackageType(command)
+	}
+
+	packageType := getPackageType(flags)
+	packages.KeepComments = flags.KeepComments
+
+	if err := packages.FromBrewfile(brewfilePath); err != nil {
+		return err
+	}
+
+	if flags.Brew {
+		name = canonicalName(cache, name)
+	}
+
+	entries := packages.Entries(packageType)
+	found := false
+	for i, e := range entries {
+		if e.Name != name {
+			continue
+		}
+
+		if e.Explicit == explicit && explicit {
+			return ErrAlreadyExplicit(name)
+		} else if e.Explicit == explicit {
+			return ErrNotExplicit(name)
+		}
+
+		entries[i].Explicit, found = explicit, true
+	}
+
+	if !found {
+		return ErrEntryDoesNotExist(name)
+	}
+
+	if flags.DryRun {
+		return printPackages(packages)
+	}
+
+	if err := writeToFile(brewfilePath, packages); err != nil {
+		return err
+	}
+
+	if explicit {
+		fmt.Printf("Marked %s '%s' as explicit in Brewfile.\n", packageType, name)
+	} else {
+		fmt.Printf("Marked %s '%s' as no longer explicit in Brewfile.\n", packageType, name)
+	}
+
+	return nil
+}
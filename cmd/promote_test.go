@@ -0,0 +1,94 @@
+package cmd_test
+
+import (
+	. "github.com/LGUG2Z/bfm/cmd"
+
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/LGUG2Z/bfm/brew"
+	"github.com/LGUG2Z/bfm/brewfile"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Promote and Demote", func() {
+	var (
+		bf     = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "src/github.com/LGUG2Z/bfm/testData/testBrewfile")
+		dbFile = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "src/github.com/LGUG2Z/bfm/testData/testDB.bolt")
+		cache  brew.Cache
+		db     *TestDB
+		f      TestFile
+	)
+
+	BeforeEach(func() {
+		testDB, err := NewTestDB(dbFile)
+		db = testDB
+		Expect(err).ToNot(HaveOccurred())
+		cache.DB = db.DB
+
+		Expect(db.AddTestBrewsFromInfo(
+			brew.Info{FullName: "curl", Dependencies: []string{"openssl"}},
+			brew.Info{FullName: "openssl"},
+		)).To(Succeed())
+
+		f = TestFile{Path: bf, Contents: "brew 'curl'\n\nbrew 'openssl' # [required by: curl]\n"}
+		Expect(f.Create()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		f.Remove()
+		db.Close()
+	})
+
+	Describe("When the command is called without any flags", func() {
+		It("Should return an error with info about required flags for specifying package types", func() {
+			err := Promote([]string{"openssl"}, &brewfile.Packages{}, cache, bf, Flags{}, brew.Required)
+			Expect(err).To(Equal(ErrNoPackageType("promote")))
+		})
+	})
+
+	Describe("When a dependency is promoted", func() {
+		It("Should keep it in the primary section and in the Brewfile after removing the brew depending on it", func() {
+			output := captureStdout(func() {
+				Expect(Promote([]string{"openssl"}, &brewfile.Packages{}, cache, bf, Flags{Brew: true}, brew.Required)).To(Succeed())
+			})
+			Expect(output).To(Equal("Marked brew 'openssl' as explicit in Brewfile.\n"))
+
+			bytes, err := ioutil.ReadFile(bf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("brew 'curl'\nbrew 'openssl' # [required by: curl] [explicit]\n"))
+
+			err = Promote([]string{"openssl"}, &brewfile.Packages{}, cache, bf, Flags{Brew: true}, brew.Required)
+			Expect(err).To(Equal(ErrAlreadyExplicit("openssl")))
+
+			_ = captureStdout(func() {
+				Expect(Remove([]string{"curl"}, &brewfile.Packages{}, cache, bf, Flags{Brew: true}, brew.Required)).To(Succeed())
+			})
+
+			bytes, err = ioutil.ReadFile(bf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("brew 'openssl' # [explicit]\n"))
+		})
+	})
+
+	Describe("When an explicit dependency is demoted", func() {
+		It("Should move it back to the dependent section", func() {
+			f = TestFile{Path: bf, Contents: "brew 'curl'\nbrew 'openssl' # [required by: curl] [explicit]\n"}
+			Expect(f.Create()).To(Succeed())
+
+			output := captureStdout(func() {
+				Expect(Demote([]string{"openssl"}, &brewfile.Packages{}, cache, bf, Flags{Brew: true}, brew.Required)).To(Succeed())
+			})
+			Expect(output).To(Equal("Marked brew 'openssl' as no longer explicit in Brewfile.\n"))
+
+			bytes, err := ioutil.ReadFile(bf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("brew 'curl'\n\nbrew 'openssl' # [required by: curl]\n"))
+
+			err = Demote([]string{"openssl"}, &brewfile.Packages{}, cache, bf, Flags{Brew: true}, brew.Required)
+			Expect(err).To(Equal(ErrNotExplicit("openssl")))
+		})
+	})
+})
@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"fmt"
 	"os/exec"
+	"strings"
+	"time"
 
 	"github.com/LGUG2Z/bfm/brew"
 	"github.com/boltdb/bolt"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // refreshCmd represents the refresh command
@@ -14,9 +18,6 @@ var refreshCmd = &cobra.Command{
 	Short: "Refresh the cache of brew formula and cask information from tapped repositories",
 	Long:  DocsRefresh,
 	Run: func(cmd *cobra.Command, args []string) {
-		brewInfo := exec.Command("brew", "info", "--all", "--json=v1")
-		caskInfo := exec.Command("brew", "search", "--casks")
-
 		db, err := bolt.Open(boltPath, 0600, nil)
 		if err != nil {
 			errorExit(err)
@@ -30,16 +31,106 @@ var refreshCmd = &cobra.Command{
 
 		cache := brew.Cache{DB: db}
 
-		err = Refresh(args, cache, brewInfo, caskInfo)
+		err = refreshCache(args, cache)
 		errorExit(err)
 	},
 }
 
+// Refreshes the cache with the info Homebrew gives for the brews and casks of
+// the installed taps.
+func refreshCache(args []string, cache brew.Cache) error {
+	brewInfo := exec.Command("brew", "info", "--all", "--json=v1")
+	caskInfo := exec.Command("brew", "search", "--casks")
+	caskMetadata := exec.Command("brew", "info", "--cask", "--json=v2", "--eval-all")
+	homebrewVersion := exec.Command("brew", "--version")
+
+	return Refresh(args, cache, brewInfo, caskInfo, caskMetadata, homebrewVersion, tapInfoCommand())
+}
+
+func tapInfoCommand() *exec.Cmd {
+	return exec.Command("brew", "tap-info", "--json", "--installed")
+}
+
+// Whether the staleness of the cache has been checked in this run. However many
+// lookups miss, Homebrew is asked for its taps, and the cache refreshed or warned
+// about, at most once.
+var checkedStaleness bool
+
+// Checks whether the cache is stale when the brew of the given name cannot be
+// found in it, the first time a lookup misses. A stale cache is refreshed if
+// BFM_AUTO_REFRESH is set, and a warning explaining why it is stale is printed
+// otherwise.
+func refreshIfStale(cache *brew.Cache, name string) (bool, error) {
+	if checkedStaleness {
+		return false, nil
+	}
+
+	checkedStaleness = true
+
+	reasons, err := cacheStaleness(cache)
+	if err != nil {
+		return false, err
+	}
+
+	if len(reasons) < 1 {
+		return false, nil
+	}
+
+	if !viper.GetBool("auto_refresh") {
+		fmt.Printf("Warning: %s is not in the cache, which is stale: %s. Run 'bfm refresh' to refresh it.\n", name, strings.Join(reasons, ", "))
+		return false, nil
+	}
+
+	fmt.Printf("%s is not in the cache, which is stale: %s. Refreshing...", name, strings.Join(reasons, ", "))
+	if err := refreshCache([]string{}, *cache); err != nil {
+		return false, err
+	}
+
+	fmt.Printf(" Done.\n")
+	return true, nil
+}
+
+// Returns the reasons the cache is stale. If Homebrew cannot say which taps are
+// installed, only the time since the cache was refreshed is taken into account.
+func cacheStaleness(cache *brew.Cache) ([]string, error) {
+	ttl, err := cacheTTL()
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := cache.Meta()
+	if err != nil {
+		return nil, err
+	}
+
+	taps, err := brew.InstalledTaps(tapInfoCommand())
+	if err != nil {
+		taps = meta.Taps
+	}
+
+	return meta.Staleness(ttl, taps, time.Now()), nil
+}
+
+// Returns the time after which the cache is stale, set with BFM_CACHE_TTL as a
+// duration such as 72h, or a week if not set. A duration of 0 never expires.
+func cacheTTL() (time.Duration, error) {
+	if !viper.IsSet("cache_ttl") {
+		return 7 * 24 * time.Hour, nil
+	}
+
+	ttl, err := time.ParseDuration(viper.GetString("cache_ttl"))
+	if err != nil {
+		return 0, ErrInvalidCacheTTL(viper.GetString("cache_ttl"))
+	}
+
+	return ttl, nil
+}
+
 func init() {
 	RootCmd.AddCommand(refreshCmd)
 }
 
-func Refresh(args []string, cache brew.Cache, brewCommand, caskCommand *exec.Cmd) error {
+func Refresh(args []string, cache brew.Cache, brewCommand, caskCommand, caskInfoCommand, versionCommand, tapInfoCommand *exec.Cmd) error {
 	if err := cache.Refresh(brewCommand); err != nil {
 		return err
 	}
@@ -48,5 +139,13 @@ func Refresh(args []string, cache brew.Cache, brewCommand, caskCommand *exec.Cmd
 		return err
 	}
 
+	if err := cache.RefreshCaskInfo(caskInfoCommand); err != nil {
+		return err
+	}
+
+	if err := cache.RefreshMeta(versionCommand, tapInfoCommand); err != nil {
+		return err
+	}
+
 	return nil
 }
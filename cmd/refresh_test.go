@@ -20,6 +20,9 @@ var _ = Describe("Refresh", func() {
 		It("It should populate the file from the output of the given command and write it to disk", func() {
 			brewCommand := exec.Command("echo", `[ { "name": "a2ps", "full_name": "a2ps" } ]`)
 			caskCommand := exec.Command("echo", `firefox    google-chrome   opera`)
+			caskInfoCommand := exec.Command("echo", `{ "casks": [ { "token": "firefox" } ] }`)
+			versionCommand := exec.Command("echo", "Homebrew 4.1.0")
+			tapInfoCommand := exec.Command("echo", `[ { "name": "homebrew/core", "HEAD": "abc" } ]`)
 			dbFile := fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "src/github.com/LGUG2Z/bfm/testData/testDB.bolt")
 
 			db, err := NewTestDB(dbFile)
@@ -27,7 +30,7 @@ var _ = Describe("Refresh", func() {
 			defer db.Close()
 			cache := brew.Cache{DB: db.DB}
 
-			Expect(Refresh([]string{}, cache, brewCommand, caskCommand)).To(Succeed())
+			Expect(Refresh([]string{}, cache, brewCommand, caskCommand, caskInfoCommand, versionCommand, tapInfoCommand)).To(Succeed())
 
 			var info brew.Info
 			var opera, firefox, chrome []byte
@@ -55,6 +58,11 @@ var _ = Describe("Refresh", func() {
 			Expect(string(opera)).To(Equal("opera"))
 			Expect(string(firefox)).To(Equal("firefox"))
 			Expect(string(chrome)).To(Equal("google-chrome"))
+
+			meta, err := cache.Meta()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(meta.HomebrewVersion).To(Equal("4.1.0"))
+			Expect(meta.Taps).To(Equal([]brew.TapInfo{{Name: "homebrew/core", Commit: "abc"}}))
 		})
 	})
 })
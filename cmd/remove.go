@@ -3,13 +3,11 @@ package cmd
 import (
 	"fmt"
 
-	"sort"
-	"strings"
-
 	"github.com/LGUG2Z/bfm/brew"
 	"github.com/LGUG2Z/bfm/brewfile"
 	"github.com/boltdb/bolt"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var removeFlags Flags
@@ -18,11 +16,17 @@ func init() {
 	RootCmd.AddCommand(removeCmd)
 
 	removeCmd.Flags().BoolVarP(&removeFlags.DryRun, "dry-run", "d", false, "conduct a dry run without modifying the Brewfile")
+	removeCmd.Flags().BoolVarP(&removeFlags.KeepComments, "keep-comments", "k", false, "keep comments and commented-out entries when rewriting the Brewfile")
 
 	removeCmd.Flags().BoolVarP(&removeFlags.Tap, "tap", "t", false, "remove a tap")
 	removeCmd.Flags().BoolVarP(&removeFlags.Brew, "brew", "b", false, "remove a brew package")
 	removeCmd.Flags().BoolVarP(&removeFlags.Cask, "cask", "c", false, "remove a cask")
 	removeCmd.Flags().BoolVarP(&removeFlags.Mas, "mas", "m", false, "remove a mas app")
+	removeCmd.Flags().BoolVarP(&removeFlags.Whalebrew, "whalebrew", "w", false, "remove a whalebrew image")
+	removeCmd.Flags().BoolVarP(&removeFlags.Vscode, "vscode", "v", false, "remove a VS Code extension")
+	removeCmd.Flags().BoolVar(&removeFlags.KeepTap, "keep-tap", false, "keep the taps of removed brews and casks even if nothing else comes from them")
+	removeCmd.Flags().BoolVar(&removeFlags.Cascade, "cascade", false, "remove the brews and casks coming from a removed tap along with it")
+	removeCmd.Flags().BoolVar(&removeFlags.Installed, "installed", false, "resolve dependencies from the runtime dependencies recorded for installed brews")
 }
 
 // removeCmd represents the remove command
@@ -32,14 +36,16 @@ var removeCmd = &cobra.Command{
 	Long:  DocsRemove,
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		var packages brewfile.Packages
+		packages := brewfile.Packages{Layout: layout}
 
 		db, err := bolt.Open(boltPath, 0600, nil)
 		if err != nil {
 			errorExit(err)
 		}
 
-		cache := brew.Cache{DB: db}
+		cache := brew.Cache{DB: db, OnMiss: refreshIfStale}
+		removeFlags.KeepComments = removeFlags.KeepComments || viper.GetBool("keep_comments")
+		removeFlags.Installed = removeFlags.Installed || viper.GetBool("installed")
 
 		error := Remove(args, &packages, cache, brewfilePath, removeFlags, level)
 		errorExit(error)
@@ -54,36 +60,60 @@ func Remove(args []string, packages *brewfile.Packages, cache brew.Cache, brewfi
 	toRemove := args[0]
 	packageType := getPackageType(flags)
 
+	packages.KeepComments = flags.KeepComments
+
 	if err := packages.FromBrewfile(brewfilePath); err != nil {
 		return err
 	}
 
-	b, err := packages.Bytes()
-	if err != nil {
-		return err
+	if flags.Brew {
+		toRemove = canonicalName(cache, toRemove)
 	}
 
-	if !entryExists(string(b), packageType, toRemove) {
-		return ErrEntryDoesNotExist(toRemove)
+	if !packages.Contains(packageType, toRemove) && !packages.Contains(packageType, args[0]) {
+		return ErrEntryDoesNotExist(args[0])
 	}
 
-	cacheMap := brew.CacheMap{Cache: &cache, Map: make(brew.Map)}
+	cacheMap := brew.CacheMap{Cache: &cache, Map: make(brew.Map), Casks: make(brew.Map), Installed: flags.Installed}
 
 	if err := cacheMap.FromPackages(packages.Brew); err != nil {
 		return err
 	}
 
+	if err := cacheMap.FromCasks(packages.Cask); err != nil {
+		return err
+	}
+
 	if err := cacheMap.ResolveDependencyMap(level); err != nil {
 		return err
 	}
 
 	if flags.Tap {
+		brews, casks := cacheMap.FromTap(toRemove)
+		if len(brews) > 0 || len(casks) > 0 {
+			if !flags.Cascade {
+				return ErrTapInUse(toRemove, append(brews, casks...))
+			}
+
+			if err := removeTapPackages(brews, casks, cacheMap, flags, level); err != nil {
+				return err
+			}
+
+			updated, err := cleanBrews(cacheMap, packages.Layout)
+			if err != nil {
+				return err
+			}
+
+			packages.Brew = updated
+		}
+
 		packages.Tap = removePackage(packageType, toRemove, packages.Tap, flags)
-		sort.Strings(packages.Tap)
 	}
 
+	taps := cacheMap.Taps()
+
 	if flags.Brew {
-		updated, err := removeBrewPackage(toRemove, cacheMap, flags, level)
+		updated, err := removeBrewPackage(toRemove, cacheMap, flags, level, packages.Layout)
 		if err != nil {
 			return err
 		}
@@ -92,20 +122,48 @@ func Remove(args []string, packages *brewfile.Packages, cache brew.Cache, brewfi
 
 	if flags.Cask {
 		packages.Cask = removePackage(packageType, toRemove, packages.Cask, flags)
-		sort.Strings(packages.Cask)
+
+		if err := cacheMap.RemoveCask(toRemove, level); err != nil {
+			return err
+		}
+
+		brews, err := cleanBrews(cacheMap, packages.Layout)
+		if err != nil {
+			return err
+		}
+
+		packages.Brew = brews
+	}
+
+	if flags.Brew || flags.Cask || flags.Tap {
+		casks, err := cleanCasks(cacheMap, packages.Layout)
+		if err != nil {
+			return err
+		}
+
+		packages.Cask = casks
+	}
+
+	if (flags.Brew || flags.Cask) && !flags.KeepTap {
+		packages.Tap = removeUnusedTaps(taps, cacheMap, packages.Tap, flags)
 	}
 
 	if flags.Mas {
 		packages.Mas = removePackage(packageType, toRemove, packages.Mas, flags)
-		sort.Strings(packages.Mas)
+	}
+
+	if flags.Whalebrew {
+		packages.Whalebrew = removePackage(packageType, toRemove, packages.Whalebrew, flags)
+	}
+
+	if flags.Vscode {
+		packages.Vscode = removePackage(packageType, toRemove, packages.Vscode, flags)
 	}
 
 	if flags.DryRun {
-		b, err := packages.Bytes()
-		if err != nil {
+		if err := printPackages(packages); err != nil {
 			return err
 		}
-		fmt.Printf(string(b))
 	} else {
 		if err := writeToFile(brewfilePath, packages); err != nil {
 			return err
@@ -115,42 +173,79 @@ func Remove(args []string, packages *brewfile.Packages, cache brew.Cache, brewfi
 	return nil
 }
 
-func removeBrewPackage(remove string, cacheMap brew.CacheMap, flags Flags, level int) ([]string, error) {
+func removeBrewPackage(remove string, cacheMap brew.CacheMap, flags Flags, level int, layout *brewfile.Layout) (brewfile.Entries, error) {
 	if err := cacheMap.Remove(remove, level); err != nil {
-		return []string{}, err
+		return nil, err
 	}
 
-	lines := []string{}
+	entries, err := cleanBrews(cacheMap, layout)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, b := range cacheMap.Map {
-		entry, err := b.Format()
-		if err != nil {
-			return []string{}, err
+	if !flags.DryRun {
+		fmt.Printf("Removed %s '%s' from Brewfile.\n", "brew", remove)
+	}
+	return entries, nil
+}
+
+// Removes the brews and casks coming from a tap which is removed, along with the
+// dependencies left without dependents.
+func removeTapPackages(brews, casks []string, cacheMap brew.CacheMap, flags Flags, level int) error {
+	for _, b := range brews {
+		if _, present := cacheMap.Map[b]; !present {
+			continue
+		}
+
+		if err := cacheMap.Remove(b, level); err != nil {
+			return err
 		}
 
-		lines = append(lines, entry)
+		if !flags.DryRun {
+			fmt.Printf("Removed %s '%s' from Brewfile.\n", "brew", b)
+		}
 	}
 
-	sort.Strings(lines)
-	if !flags.DryRun {
-		fmt.Printf("Removed %s '%s' from Brewfile.\n", "brew", remove)
+	for _, c := range casks {
+		if _, present := cacheMap.Casks[c]; !present {
+			continue
+		}
+
+		if err := cacheMap.RemoveCask(c, level); err != nil {
+			return err
+		}
+
+		if !flags.DryRun {
+			fmt.Printf("Removed %s '%s' from Brewfile.\n", "cask", c)
+		}
 	}
-	return lines, nil
+
+	return nil
 }
 
-func removePackage(packageType, packageToRemove string, packages []string, flags Flags) []string {
-	updatedPackages := []string{}
-	entryToRemove := constructBaseEntry(packageType, packageToRemove)
+// Removes the given taps, which brews or casks came from before removing a
+// package, from the taps of the Brewfile if no brew or cask comes from them any
+// more.
+func removeUnusedTaps(taps []string, cacheMap brew.CacheMap, entries brewfile.Entries, flags Flags) brewfile.Entries {
+	for _, t := range taps {
+		if brews, casks := cacheMap.FromTap(t); len(brews) > 0 || len(casks) > 0 {
+			continue
+		}
 
-	for _, p := range packages {
-		if !strings.HasPrefix(p, entryToRemove) {
-			updatedPackages = append(updatedPackages, p)
-		} else {
-			if !flags.DryRun {
-				fmt.Printf("Removed %s '%s' from Brewfile.\n", packageType, packageToRemove)
+		for _, e := range entries {
+			if brew.SameTap(e.Name, t) {
+				entries = removePackage("tap", e.Name, entries, flags)
 			}
 		}
 	}
 
-	return updatedPackages
+	return entries
+}
+
+func removePackage(packageType, packageToRemove string, packages brewfile.Entries, flags Flags) brewfile.Entries {
+	if packages.Contains(packageToRemove) && !flags.DryRun {
+		fmt.Printf("Removed %s '%s' from Brewfile.\n", packageType, packageToRemove)
+	}
+
+	return packages.Remove(packageToRemove)
 }
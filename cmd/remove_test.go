@@ -109,6 +109,25 @@ var _ = Describe("Remove", func() {
 
 		})
 
+		It("Should remove the brews only a removed cask depends on", func() {
+			var thing brew.CaskInfo
+			thing.Token, thing.DependsOn.Formula = "docker-thing", []string{"jq"}
+			Expect(db.AddTestCasksFromInfo(thing)).To(Succeed())
+			Expect(db.AddTestBrewsByName("jq", "vim")).To(Succeed())
+
+			t := TestFile{Path: bf, Contents: "brew 'vim'\nbrew 'jq' # [required by: docker-thing]\ncask 'docker-thing'\n"}
+			Expect(t.Create()).To(Succeed())
+			defer t.Remove()
+
+			_ = captureStdout(func() {
+				Expect(Remove([]string{"docker-thing"}, &packages, cache, bf, Flags{Cask: true}, brew.Required)).To(Succeed())
+			})
+
+			bytes, err := ioutil.ReadFile(bf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("brew 'vim'\n"))
+		})
+
 		It("Should remove a mas entry from the Brewfile", func() {
 			t := TestFile{Path: bf, Contents: "mas 'Xcode', id: 123456"}
 			Expect(t.Create()).To(Succeed())
@@ -124,6 +143,43 @@ var _ = Describe("Remove", func() {
 			Expect(bytes).To(Equal([]byte("")))
 
 		})
+
+		It("Should remove a whalebrew entry from the Brewfile", func() {
+			t := TestFile{Path: bf, Contents: "whalebrew 'whalebrew/wget'\nvscode 'golang.go'"}
+			Expect(t.Create()).To(Succeed())
+			defer t.Remove()
+
+			_ = captureStdout(func() {
+				error := Remove([]string{"whalebrew/wget"}, &packages, cache, bf, Flags{Whalebrew: true}, 0)
+				Expect(error).ToNot(HaveOccurred())
+			})
+
+			bytes, error := ioutil.ReadFile(bf)
+			Expect(error).ToNot(HaveOccurred())
+			Expect(bytes).To(Equal([]byte("vscode 'golang.go'\n")))
+		})
+	})
+
+	Describe("When the command is called for an entry in an included Brewfile", func() {
+		It("Should remove the entry from the included Brewfile", func() {
+			work := fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "src/github.com/LGUG2Z/bfm/testData/testBrewfile.work")
+
+			t := TestFile{Path: bf, Contents: "cask 'firefox'\ninstance_eval(File.read('testBrewfile.work'))\n"}
+			Expect(t.Create()).To(Succeed())
+			defer t.Remove()
+
+			w := TestFile{Path: work, Contents: "cask 'slack'\ncask 'zoom'\n"}
+			Expect(w.Create()).To(Succeed())
+			defer w.Remove()
+
+			_ = captureStdout(func() {
+				Expect(Remove([]string{"zoom"}, &packages, cache, bf, Flags{Cask: true}, 0)).To(Succeed())
+			})
+
+			bytes, error := ioutil.ReadFile(work)
+			Expect(error).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("cask 'slack'\n"))
+		})
 	})
 
 	Describe("When the command is called for a brew entry with level Required", func() {
@@ -167,8 +223,8 @@ brew 'zsh'
 			Expect(error).ToNot(HaveOccurred())
 
 			Expect(packages.Brew).To(HaveLen(2))
-			Expect(packages.Brew[0]).To(Equal("brew 'bash' # [required by: zsh]"))
-			Expect(packages.Brew[1]).To(Equal("brew 'zsh'"))
+			Expect(packages.Brew[0].String()).To(Equal("brew 'bash' # [required by: zsh]"))
+			Expect(packages.Brew[1].String()).To(Equal("brew 'zsh'"))
 
 		})
 	})
@@ -238,9 +294,65 @@ brew 'vim'
 			Expect(error).ToNot(HaveOccurred())
 
 			Expect(packages.Brew).To(HaveLen(2))
-			Expect(packages.Brew[0]).To(Equal("brew 'bash' # [required by: vim]"))
-			Expect(packages.Brew[1]).To(Equal("brew 'vim'"))
+			Expect(packages.Brew[0].String()).To(Equal("brew 'bash' # [required by: vim]"))
+			Expect(packages.Brew[1].String()).To(Equal("brew 'vim'"))
+
+		})
+	})
+
+	Describe("When removing brews from taps", func() {
+		var t TestFile
+
+		BeforeEach(func() {
+			Expect(db.AddTestBrewsFromInfo(
+				brew.Info{FullName: "crisidev/chunkwm/chunkwm", Dependencies: []string{"crisidev/chunkwm/chunkc"}},
+				brew.Info{FullName: "crisidev/chunkwm/chunkc"},
+				brew.Info{FullName: "jq"},
+			)).To(Succeed())
+
+			t = TestFile{Path: bf, Contents: "tap 'crisidev/chunkwm'\n\nbrew 'crisidev/chunkwm/chunkwm'\nbrew 'jq'\n\nbrew 'crisidev/chunkwm/chunkc' # [required by: crisidev/chunkwm/chunkwm]\n"}
+			Expect(t.Create()).To(Succeed())
+		})
+
+		AfterEach(func() {
+			t.Remove()
+		})
+
+		It("Should remove the tap once no brew comes from it", func() {
+			output := captureStdout(func() {
+				Expect(Remove([]string{"crisidev/chunkwm/chunkwm"}, &packages, cache, bf, Flags{Brew: true}, brew.Required)).To(Succeed())
+			})
+			Expect(output).To(Equal("Removed brew 'crisidev/chunkwm/chunkwm' from Brewfile.\nRemoved tap 'crisidev/chunkwm' from Brewfile.\n"))
+
+			bytes, err := ioutil.ReadFile(bf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("brew 'jq'\n"))
+		})
+
+		It("Should keep the tap with the --keep-tap flag", func() {
+			_ = captureStdout(func() {
+				Expect(Remove([]string{"crisidev/chunkwm/chunkwm"}, &packages, cache, bf, Flags{Brew: true, KeepTap: true}, brew.Required)).To(Succeed())
+			})
+
+			bytes, err := ioutil.ReadFile(bf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("tap 'crisidev/chunkwm'\n\nbrew 'jq'\n"))
+		})
+
+		It("Should refuse to remove a tap brews still come from", func() {
+			err := Remove([]string{"crisidev/chunkwm"}, &packages, cache, bf, Flags{Tap: true}, brew.Required)
+			Expect(err).To(Equal(ErrTapInUse("crisidev/chunkwm", []string{"crisidev/chunkwm/chunkc", "crisidev/chunkwm/chunkwm"})))
+		})
+
+		It("Should remove the brews coming from a tap along with it with the --cascade flag", func() {
+			output := captureStdout(func() {
+				Expect(Remove([]string{"crisidev/chunkwm"}, &packages, cache, bf, Flags{Tap: true, Cascade: true}, brew.Required)).To(Succeed())
+			})
+			Expect(output).To(Equal("Removed brew 'crisidev/chunkwm/chunkc' from Brewfile.\nRemoved brew 'crisidev/chunkwm/chunkwm' from Brewfile.\nRemoved tap 'crisidev/chunkwm' from Brewfile.\n"))
 
+			bytes, err := ioutil.ReadFile(bf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("brew 'jq'\n"))
 		})
 	})
 })
@@ -4,9 +4,8 @@ import (
 	"fmt"
 	"os"
 
-	"strings"
-
 	"github.com/LGUG2Z/bfm/brew"
+	"github.com/LGUG2Z/bfm/brewfile"
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -25,6 +24,17 @@ var RootCmd = &cobra.Command{
 			refreshCmd.Run(refreshCmd, []string{""})
 			fmt.Printf(" Done.\n\n")
 		}
+
+		rebuild, err := migrateCache(boltPath)
+		if err != nil {
+			errorExit(err)
+		}
+
+		if rebuild && cmd != refreshCmd {
+			fmt.Printf("Cache built by an older version of bfm. Rebuilding...")
+			refreshCmd.Run(refreshCmd, []string{""})
+			fmt.Printf(" Done.\n\n")
+		}
 	}}
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -40,6 +50,7 @@ var (
 	brewfilePath string
 	boltPath     string
 	level        int
+	layout       *brewfile.Layout
 )
 
 func init() {
@@ -47,9 +58,10 @@ func init() {
 }
 
 type Flags struct {
-	Brew, Tap, Cask, Mas, DryRun bool
-	Args                         []string
-	RestartService, MasID        string
+	Brew, Tap, Cask, Mas, Whalebrew, Vscode, DryRun, KeepComments, StartService, Installed, Reverse, KeepTap, Cascade bool
+	Args, ConflictsWith                                                                                               []string
+	RestartService, Link, Postinstall, MasID, When, File, Level, Format                                               string
+	Depth                                                                                                             int
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -91,18 +103,46 @@ func initConfig() {
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Println("Using config file:", viper.ConfigFileUsed())
 	}
+
+	var err error
+	layout, err = resolveLayout()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// Reads the layout Brewfiles are written with from the layout section of the
+// config file, using the default layout for anything not set.
+func resolveLayout() (*brewfile.Layout, error) {
+	l := brewfile.DefaultLayout()
+
+	if viper.IsSet("layout.sections") {
+		l.Sections = viper.GetStringSlice("layout.sections")
+	}
+
+	if viper.IsSet("layout.headers") {
+		l.Headers = viper.GetStringMapString("layout.headers")
+	}
+
+	if viper.IsSet("layout.blank_lines") {
+		l.BlankLines = viper.GetInt("layout.blank_lines")
+	}
+
+	if viper.IsSet("layout.quote") {
+		l.Quote = viper.GetString("layout.quote")
+	}
+
+	if viper.IsSet("layout.annotation") {
+		l.Annotation = viper.GetString("layout.annotation")
+	}
+
+	return l, l.Validate()
 }
 
 func resolveDependencyLevel(level string) (int, error) {
-	switch strings.ToLower(level) {
-	case "required":
-		return brew.Required, nil
-	case "recommended":
-		return brew.Recommended, nil
-	case "optional":
-		return brew.Optional, nil
-	case "build":
-		return brew.Build, nil
+	if l, ok := brew.ParseLevel(level); ok {
+		return l, nil
 	}
 
 	return 0, ErrDependencyLevelNotSet
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/LGUG2Z/bfm/brew"
+	"github.com/LGUG2Z/bfm/brewfile"
+	"github.com/boltdb/bolt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var treeFlags Flags
+
+func init() {
+	RootCmd.AddCommand(treeCmd)
+
+	treeCmd.Flags().BoolVarP(&treeFlags.Reverse, "reverse", "r", false, "show the brews depending on each brew instead of its dependencies")
+	treeCmd.Flags().BoolVar(&treeFlags.Installed, "installed", false, "resolve dependencies from the runtime dependencies recorded for installed brews")
+}
+
+// treeCmd represents the tree command
+var treeCmd = &cobra.Command{
+	Use:   "tree",
+	Short: "Show the dependency tree of your Brewfile",
+	Long:  DocsTree,
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		packages := brewfile.Packages{Layout: layout}
+
+		db, err := bolt.Open(boltPath, 0600, nil)
+		if err != nil {
+			errorExit(err)
+		}
+
+		cache := brew.Cache{DB: db, OnMiss: refreshIfStale}
+		treeFlags.Installed = treeFlags.Installed || viper.GetBool("installed")
+
+		err = Tree(args, &packages, cache, brewfilePath, treeFlags, level)
+		errorExit(err)
+	},
+}
+
+func Tree(args []string, packages *brewfile.Packages, cache brew.Cache, brewfilePath string, flags Flags, level int) error {
+	if err := packages.FromBrewfile(brewfilePath); err != nil {
+		return err
+	}
+
+	cacheMap := brew.CacheMap{Cache: &cache, Map: make(brew.Map), Casks: make(brew.Map), Installed: flags.Installed}
+	if err := cacheMap.FromPackages(packages.Brew); err != nil {
+		return err
+	}
+
+	if err := cacheMap.FromCasks(packages.Cask); err != nil {
+		return err
+	}
+
+	if err := cacheMap.ResolveDependencyMap(level); err != nil {
+		return err
+	}
+
+	var root string
+	if len(args) > 0 {
+		name, err := fullName(cacheMap, args[0])
+		if err != nil {
+			return err
+		}
+
+		if _, present := cacheMap.Lookup(name); !present {
+			return ErrEntryDoesNotExist(name)
+		}
+
+		root = name
+	}
+
+	fmt.Print(cacheMap.Tree(root, flags.Reverse))
+	return nil
+}
@@ -0,0 +1,62 @@
+package cmd_test
+
+import (
+	. "github.com/LGUG2Z/bfm/cmd"
+
+	"fmt"
+	"os"
+
+	"github.com/LGUG2Z/bfm/brew"
+	"github.com/LGUG2Z/bfm/brewfile"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tree", func() {
+	var (
+		bf     = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "src/github.com/LGUG2Z/bfm/testData/testBrewfile")
+		dbFile = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "src/github.com/LGUG2Z/bfm/testData/testDB.bolt")
+		cache  brew.Cache
+		f      TestFile
+		db     *TestDB
+	)
+
+	BeforeEach(func() {
+		f = TestFile{Path: bf, Contents: "brew 'neovim'\nbrew 'weechat'\nbrew 'gettext' # [required by: neovim, weechat]\n"}
+		Expect(f.Create()).To(Succeed())
+
+		testDB, err := NewTestDB(dbFile)
+		db = testDB
+		Expect(err).ToNot(HaveOccurred())
+		cache.DB = db.DB
+
+		Expect(db.AddTestBrewsFromInfo(
+			brew.Info{FullName: "neovim", Dependencies: []string{"gettext"}},
+			brew.Info{FullName: "weechat", Dependencies: []string{"gettext"}},
+			brew.Info{FullName: "gettext"},
+		)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		f.Remove()
+		db.Close()
+	})
+
+	Describe("When the command is called", func() {
+		It("Should print the dependencies of every primary brew", func() {
+			output := captureStdout(func() {
+				Expect(Tree([]string{}, &brewfile.Packages{}, cache, bf, Flags{}, brew.Required)).To(Succeed())
+			})
+
+			Expect(output).To(Equal("neovim\n└── gettext (required)\nweechat\n└── gettext (required)\n"))
+		})
+
+		It("Should print the brews depending on a brew in reverse", func() {
+			output := captureStdout(func() {
+				Expect(Tree([]string{"gettext"}, &brewfile.Packages{}, cache, bf, Flags{Reverse: true}, brew.Required)).To(Succeed())
+			})
+
+			Expect(output).To(Equal("gettext\n├── neovim (required)\n└── weechat (required)\n"))
+		})
+	})
+})
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/LGUG2Z/bfm/brew"
+	"github.com/LGUG2Z/bfm/brewfile"
+	"github.com/boltdb/bolt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var whyFlags Flags
+
+func init() {
+	RootCmd.AddCommand(whyCmd)
+
+	whyCmd.Flags().BoolVar(&whyFlags.Installed, "installed", false, "resolve dependencies from the runtime dependencies recorded for installed brews")
+}
+
+// whyCmd represents the why command
+var whyCmd = &cobra.Command{
+	Use:   "why",
+	Short: "Explain why a brew is in your Brewfile",
+	Long:  DocsWhy,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		packages := brewfile.Packages{Layout: layout}
+
+		db, err := bolt.Open(boltPath, 0600, nil)
+		if err != nil {
+			errorExit(err)
+		}
+
+		cache := brew.Cache{DB: db, OnMiss: refreshIfStale}
+		whyFlags.Installed = whyFlags.Installed || viper.GetBool("installed")
+
+		err = Why(args, &packages, cache, brewfilePath, whyFlags, level)
+		errorExit(err)
+	},
+}
+
+func Why(args []string, packages *brewfile.Packages, cache brew.Cache, brewfilePath string, flags Flags, level int) error {
+	if err := packages.FromBrewfile(brewfilePath); err != nil {
+		return err
+	}
+
+	cacheMap := brew.CacheMap{Cache: &cache, Map: make(brew.Map), Casks: make(brew.Map), Installed: flags.Installed}
+	if err := cacheMap.FromPackages(packages.Brew); err != nil {
+		return err
+	}
+
+	if err := cacheMap.FromCasks(packages.Cask); err != nil {
+		return err
+	}
+
+	if err := cacheMap.ResolveDependencyMap(level); err != nil {
+		return err
+	}
+
+	toExplain, err := fullName(cacheMap, args[0])
+	if err != nil {
+		return err
+	}
+
+	explained, present := cacheMap.Lookup(toExplain)
+	if !present {
+		fmt.Printf("%s is not present in the Brewfile.\n", toExplain)
+		return nil
+	}
+
+	chains := cacheMap.Chains(toExplain)
+	if len(chains) < 1 {
+		fmt.Printf("%s is a primary brew: no other brew in the Brewfile depends on it.\n", toExplain)
+		return nil
+	}
+
+	fmt.Printf("%s is in the Brewfile because of:\n\n", toExplain)
+	for _, c := range chains {
+		fmt.Println(c)
+	}
+
+	if explained.Explicit {
+		fmt.Printf("\n%s is also explicit, so it stays when these brews are removed.\n", toExplain)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package cmd_test
+
+import (
+	. "github.com/LGUG2Z/bfm/cmd"
+
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/LGUG2Z/bfm/brew"
+	"github.com/LGUG2Z/bfm/brewfile"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Why", func() {
+	var (
+		bf     = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "src/github.com/LGUG2Z/bfm/testData/testBrewfile")
+		dbFile = fmt.Sprintf("%s/%s", os.Getenv("GOPATH"), "src/github.com/LGUG2Z/bfm/testData/testDB.bolt")
+		cache  brew.Cache
+		f      TestFile
+		db     *TestDB
+	)
+
+	BeforeEach(func() {
+		f = TestFile{Path: bf, Contents: "brew 'neovim'\nbrew 'vim'\nbrew 'gettext' # [required by: neovim]\nbrew 'python@3.9' # [required by: gettext, vim]\n"}
+		Expect(f.Create()).To(Succeed())
+
+		testDB, err := NewTestDB(dbFile)
+		db = testDB
+		Expect(err).ToNot(HaveOccurred())
+		cache.DB = db.DB
+
+		Expect(db.AddTestBrewsFromInfo(
+			brew.Info{FullName: "neovim", Dependencies: []string{"gettext"}},
+			brew.Info{FullName: "vim", Dependencies: []string{"python@3.9"}},
+			brew.Info{FullName: "gettext", Dependencies: []string{"python@3.9"}},
+			brew.Info{FullName: "python@3.9"},
+		)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		f.Remove()
+		db.Close()
+	})
+
+	Describe("When the command is called", func() {
+		It("Should print every chain of dependencies leading to the brew", func() {
+			output := captureStdout(func() {
+				Expect(Why([]string{"python@3.9"}, &brewfile.Packages{}, cache, bf, Flags{}, brew.Required)).To(Succeed())
+			})
+
+			Expect(output).To(Equal(`python@3.9 is in the Brewfile because of:
+
+neovim -> gettext (required) -> python@3.9 (required)
+vim -> python@3.9 (required)
+`))
+		})
+
+		It("Should say when no other brew depends on the brew", func() {
+			output := captureStdout(func() {
+				Expect(Why([]string{"vim"}, &brewfile.Packages{}, cache, bf, Flags{}, brew.Required)).To(Succeed())
+			})
+
+			Expect(output).To(Equal("vim is a primary brew: no other brew in the Brewfile depends on it.\n"))
+		})
+
+		It("Should explain the casks required by brews in the Brewfile", func() {
+			var wine brew.Info
+			Expect(json.Unmarshal([]byte(`{"full_name": "wine", "requirements": [{"name": "x11", "cask": "xquartz"}]}`), &wine)).To(Succeed())
+			Expect(db.AddTestBrewsFromInfo(wine)).To(Succeed())
+			Expect(db.AddTestCasksFromInfo(brew.CaskInfo{Token: "xquartz"})).To(Succeed())
+
+			f := TestFile{Path: bf, Contents: "brew 'wine'\n"}
+			Expect(f.Create()).To(Succeed())
+
+			output := captureStdout(func() {
+				Expect(Why([]string{"xquartz"}, &brewfile.Packages{}, cache, bf, Flags{}, brew.Required)).To(Succeed())
+			})
+
+			Expect(output).To(Equal("xquartz is in the Brewfile because of:\n\nwine -> xquartz (required)\n"))
+		})
+	})
+})
@@ -0,0 +1,161 @@
+package diagnostics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Position is a position in a Brewfile. Lines and columns start at 1, and a
+// zero Line means the position is unknown.
+type Position struct {
+	Filename     string
+	Line, Column int
+}
+
+func (p Position) String() string {
+	if len(p.Filename) > 0 {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+	}
+
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Reports whether the position points to a line of a Brewfile.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// Diagnostic is an error found at a position in a Brewfile. The hint, if any,
+// explains how to fix the error and is only printed once when the same hint
+// applies to several diagnostics.
+type Diagnostic struct {
+	Pos     Position
+	Message string
+	Hint    string
+}
+
+func (d *Diagnostic) Error() string {
+	return strings.Join(append([]string{d.line()}, hints([]*Diagnostic{d})...), "\n")
+}
+
+func (d *Diagnostic) line() string {
+	if !d.Pos.IsValid() {
+		return d.Message
+	}
+
+	return fmt.Sprintf("%s: %s", d.Pos, d.Message)
+}
+
+// Creates a Diagnostic at the given position.
+func Errorf(pos Position, format string, args ...interface{}) error {
+	return &Diagnostic{Pos: pos, Message: fmt.Sprintf(format, args...)}
+}
+
+// List collects the diagnostics of a Brewfile so that they can all be reported
+// together instead of stopping at the first one.
+type List []*Diagnostic
+
+// Adds an error to the list. Errors which are already diagnostics, or lists of
+// diagnostics, keep their own positions; any other error, or diagnostic
+// without a position, is given the position passed in.
+func (l *List) Add(pos Position, err error) {
+	switch err := err.(type) {
+	case nil:
+		return
+	case *Diagnostic:
+		if !err.Pos.IsValid() {
+			err = &Diagnostic{Pos: pos, Message: err.Message, Hint: err.Hint}
+		}
+
+		l.add(err)
+	case List:
+		for _, d := range err {
+			l.add(d)
+		}
+	default:
+		l.add(&Diagnostic{Pos: pos, Message: strings.TrimSpace(err.Error())})
+	}
+}
+
+// Adds a diagnostic to the list unless the same one has already been added.
+func (l *List) add(d *Diagnostic) {
+	for _, existing := range *l {
+		if existing.Pos == d.Pos && existing.Message == d.Message {
+			return
+		}
+	}
+
+	*l = append(*l, d)
+}
+
+// Reports whether there is a diagnostic at the given position.
+func (l List) At(pos Position) bool {
+	for _, d := range l {
+		if d.Pos == pos {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sorts the list by filename, line and column. Diagnostics without a position
+// are kept after the others in the order they were added.
+func (l List) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		a, b := l[i].Pos, l[j].Pos
+
+		switch {
+		case a.IsValid() != b.IsValid():
+			return a.IsValid()
+		case a.Filename != b.Filename:
+			return a.Filename < b.Filename
+		case a.Line != b.Line:
+			return a.Line < b.Line
+		}
+
+		return a.Column < b.Column
+	})
+}
+
+// Returns the list as an error, or nil if it is empty.
+func (l List) Err() error {
+	if len(l) < 1 {
+		return nil
+	}
+
+	l.Sort()
+	return l
+}
+
+// Formats the list with one diagnostic per line, followed by their hints.
+func (l List) Error() string {
+	lines := make([]string, len(l))
+	for i, d := range l {
+		lines[i] = d.line()
+	}
+
+	return strings.Join(append(lines, hints(l)...), "\n")
+}
+
+// Returns the distinct hints of the given diagnostics in order.
+func hints(diagnostics []*Diagnostic) []string {
+	var hints []string
+	for _, d := range diagnostics {
+		if len(d.Hint) < 1 {
+			continue
+		}
+
+		seen := false
+		for _, h := range hints {
+			seen = seen || h == d.Hint
+		}
+
+		if !seen {
+			hints = append(hints, d.Hint)
+		}
+	}
+
+	return hints
+}
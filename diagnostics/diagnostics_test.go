@@ -0,0 +1,61 @@
+package diagnostics_test
+
+import (
+	. "github.com/LGUG2Z/bfm/diagnostics"
+
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Diagnostics", func() {
+	It("Prefixes messages with the position they were found at", func() {
+		Expect(Errorf(Position{Filename: "Brewfile", Line: 3, Column: 1}, "unknown option %s", "foo").Error()).To(Equal("Brewfile:3:1: unknown option foo"))
+		Expect(Errorf(Position{}, "cache not found").Error()).To(Equal("cache not found"))
+	})
+
+	Describe("When collecting errors", func() {
+		It("Is not an error when empty", func() {
+			var list List
+			Expect(list.Err()).ToNot(HaveOccurred())
+		})
+
+		It("Reports all errors sorted by position, one per line", func() {
+			var list List
+			list.Add(Position{Filename: "Brewfile", Line: 9, Column: 1}, errors.New("could not find info for vim"))
+			list.Add(Position{}, errors.New("cache is stale"))
+			list.Add(Position{}, Errorf(Position{Filename: "Brewfile", Line: 2, Column: 6}, "unterminated string"))
+
+			Expect(list.Err()).To(HaveOccurred())
+			Expect(list.Err().Error()).To(Equal("Brewfile:2:6: unterminated string\nBrewfile:9:1: could not find info for vim\ncache is stale"))
+		})
+
+		It("Prints each hint once after the errors", func() {
+			var list List
+			list.Add(Position{Filename: "Brewfile", Line: 1, Column: 1}, &Diagnostic{Message: "could not find a", Hint: "run refresh"})
+			list.Add(Position{Filename: "Brewfile", Line: 2, Column: 1}, &Diagnostic{Message: "could not find b", Hint: "run refresh"})
+
+			Expect(list.Err().Error()).To(Equal("Brewfile:1:1: could not find a\nBrewfile:2:1: could not find b\nrun refresh"))
+		})
+
+		It("Does not report the same error twice", func() {
+			var list List
+			pos := Position{Filename: "Brewfile", Line: 2, Column: 6}
+			list.Add(pos, errors.New("unterminated string"))
+			list.Add(pos, errors.New("unterminated string"))
+
+			Expect(list).To(HaveLen(1))
+			Expect(list.At(pos)).To(BeTrue())
+		})
+
+		It("Merges lists of errors", func() {
+			var first, second List
+			first.Add(Position{Filename: "a", Line: 1, Column: 1}, errors.New("one"))
+			second.Add(Position{Filename: "b", Line: 1, Column: 1}, errors.New("two"))
+			first.Add(Position{}, second)
+
+			Expect(first).To(HaveLen(2))
+		})
+	})
+})
@@ -0,0 +1,12 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrCycle = func(path []string) error {
+		return fmt.Errorf("Dependency cycle: %s.", strings.Join(path, " -> "))
+	}
+)
@@ -0,0 +1,124 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+var kindNames = map[Kind]string{
+	Required:    "required",
+	Recommended: "recommended",
+	Optional:    "optional",
+	Build:       "build",
+}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+func (k Kind) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k.String())
+}
+
+// Node is a package in an exported dependency graph. A primary node is one which
+// no other node depends on.
+type Node struct {
+	Name    string `json:"name"`
+	Tap     string `json:"tap"`
+	Type    string `json:"type"`
+	Primary bool   `json:"primary"`
+}
+
+// Edge is a dependency of one package on another in an exported dependency graph.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind Kind   `json:"kind"`
+}
+
+// Export is a dependency graph exported for rendering.
+type Export struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Sorts the nodes by name and the edges by the nodes they connect and their kind.
+func (e Export) Sort() {
+	sort.Slice(e.Nodes, func(i, j int) bool {
+		return e.Nodes[i].Name < e.Nodes[j].Name
+	})
+
+	sort.Slice(e.Edges, func(i, j int) bool {
+		a, b := e.Edges[i], e.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+
+		if a.To != b.To {
+			return a.To < b.To
+		}
+
+		return a.Kind < b.Kind
+	})
+}
+
+// Renders the graph in the DOT language of Graphviz, with primary nodes drawn as
+// boxes and every edge labelled with its kind.
+func (e Export) DOT() []byte {
+	var b bytes.Buffer
+
+	b.WriteString("digraph bfm {\n")
+	for _, n := range e.Nodes {
+		shape := "ellipse"
+		if n.Primary {
+			shape = "box"
+		}
+
+		fmt.Fprintf(&b, "\t%q [shape=%s, tooltip=%q];\n", n.Name, shape, n.Tap)
+	}
+
+	for _, edge := range e.Edges {
+		fmt.Fprintf(&b, "\t%q -> %q [label=%q];\n", edge.From, edge.To, edge.Kind.String())
+	}
+
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+// Renders the graph as a Mermaid flowchart, with primary nodes drawn as
+// rectangles and every edge labelled with its kind. Nodes are given identifiers
+// of their own, as package names are not valid Mermaid identifiers.
+func (e Export) Mermaid() []byte {
+	var b bytes.Buffer
+	ids := make(map[string]string)
+
+	b.WriteString("graph TD\n")
+	for i, n := range e.Nodes {
+		ids[n.Name] = fmt.Sprintf("n%d", i)
+
+		format := "\t%s(%q)\n"
+		if n.Primary {
+			format = "\t%s[%q]\n"
+		}
+
+		fmt.Fprintf(&b, format, ids[n.Name], n.Name)
+	}
+
+	for _, edge := range e.Edges {
+		fmt.Fprintf(&b, "\t%s -->|%s| %s\n", ids[edge.From], edge.Kind, ids[edge.To])
+	}
+
+	return b.Bytes()
+}
+
+// Renders the graph as indented JSON.
+func (e Export) JSON() ([]byte, error) {
+	b, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
@@ -0,0 +1,60 @@
+package graph_test
+
+import (
+	. "github.com/LGUG2Z/bfm/graph"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Export", func() {
+	var export Export
+
+	BeforeEach(func() {
+		export = Export{
+			Nodes: []Node{
+				{Name: "neovim", Tap: "homebrew/core", Type: "brew", Primary: true},
+				{Name: "gettext", Tap: "homebrew/core", Type: "brew"},
+				{Name: "cmake", Tap: "homebrew/core", Type: "brew"},
+			},
+			Edges: []Edge{
+				{From: "neovim", To: "gettext", Kind: Required},
+				{From: "neovim", To: "cmake", Kind: Build},
+			},
+		}
+		export.Sort()
+	})
+
+	It("Should render the graph in the DOT language", func() {
+		Expect(string(export.DOT())).To(Equal(`digraph bfm {
+	"cmake" [shape=ellipse, tooltip="homebrew/core"];
+	"gettext" [shape=ellipse, tooltip="homebrew/core"];
+	"neovim" [shape=box, tooltip="homebrew/core"];
+	"neovim" -> "cmake" [label="build"];
+	"neovim" -> "gettext" [label="required"];
+}
+`))
+	})
+
+	It("Should render the graph as a Mermaid flowchart", func() {
+		Expect(string(export.Mermaid())).To(Equal(`graph TD
+	n0("cmake")
+	n1("gettext")
+	n2["neovim"]
+	n2 -->|build| n0
+	n2 -->|required| n1
+`))
+	})
+
+	It("Should render the graph as JSON with the kinds of the edges by name", func() {
+		b, err := export.JSON()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(b)).To(ContainSubstring(`"name": "neovim",
+      "tap": "homebrew/core",
+      "type": "brew",
+      "primary": true`))
+		Expect(string(b)).To(ContainSubstring(`"from": "neovim",
+      "to": "cmake",
+      "kind": "build"`))
+	})
+})
@@ -0,0 +1,92 @@
+package graph
+
+// Kind is the type of the dependency an edge stands for.
+type Kind int
+
+const (
+	Required Kind = 1 << iota
+	Recommended
+	Optional
+	Build
+)
+
+// Graph is a dependency graph with typed edges. The edges of a node are loaded the
+// first time they are needed and kept from then on, and the nodes reachable from
+// a node are only walked once for each kind of edge, however many paths lead to it.
+type Graph struct {
+	expand func(node string, kind Kind) ([]string, error)
+	edges  map[Kind]map[string][]string
+	walked map[Kind]map[string]bool
+}
+
+// Creates a Graph which loads the edges of a kind leaving a node with expand.
+func New(expand func(node string, kind Kind) ([]string, error)) *Graph {
+	return &Graph{
+		expand: expand,
+		edges:  make(map[Kind]map[string][]string),
+		walked: make(map[Kind]map[string]bool),
+	}
+}
+
+// Returns the nodes a node has edges of the given kind to.
+func (g *Graph) Edges(node string, kind Kind) ([]string, error) {
+	if edges, loaded := g.edges[kind][node]; loaded {
+		return edges, nil
+	}
+
+	edges, err := g.expand(node, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	if g.edges[kind] == nil {
+		g.edges[kind] = make(map[string][]string)
+	}
+
+	g.edges[kind][node] = edges
+	return edges, nil
+}
+
+// Visits the edge from one node to another and then every edge of the same kind
+// reachable from there. The edges leading to a node which has already been walked
+// are visited without walking the node again. An edge leading back to a node on
+// the current path is reported as a cycle.
+func (g *Graph) Walk(from, to string, kind Kind, visit func(from, to string) error) error {
+	return g.walk(from, to, kind, visit, []string{from})
+}
+
+func (g *Graph) walk(from, to string, kind Kind, visit func(from, to string) error, path []string) error {
+	for i, p := range path {
+		if p == to {
+			cycle := append([]string{}, path[i:]...)
+			return ErrCycle(append(cycle, to))
+		}
+	}
+
+	if err := visit(from, to); err != nil {
+		return err
+	}
+
+	if g.walked[kind][to] {
+		return nil
+	}
+
+	edges, err := g.Edges(to, kind)
+	if err != nil {
+		return err
+	}
+
+	path = append(path, to)
+	for _, next := range edges {
+		if err := g.walk(to, next, kind, visit, path); err != nil {
+			return err
+		}
+	}
+
+	if g.walked[kind] == nil {
+		g.walked[kind] = make(map[string]bool)
+	}
+
+	g.walked[kind][to] = true
+	return nil
+}
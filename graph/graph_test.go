@@ -0,0 +1,88 @@
+package graph_test
+
+import (
+	. "github.com/LGUG2Z/bfm/graph"
+
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Graph", func() {
+	var (
+		edges    map[Kind]map[string][]string
+		expanded map[string]int
+		visited  []string
+		g        *Graph
+	)
+
+	visit := func(from, to string) error {
+		visited = append(visited, from+" -> "+to)
+		return nil
+	}
+
+	BeforeEach(func() {
+		edges = map[Kind]map[string][]string{
+			Required: {
+				"neovim":   {"gettext", "libuv"},
+				"gettext":  {"readline"},
+				"libuv":    {"readline"},
+				"readline": {"ncurses"},
+			},
+			Build: {
+				"neovim": {"cmake"},
+			},
+		}
+		expanded = make(map[string]int)
+		visited = nil
+
+		g = New(func(node string, kind Kind) ([]string, error) {
+			if node == "broken" {
+				return nil, errors.New("broken")
+			}
+
+			expanded[node]++
+			return edges[kind][node], nil
+		})
+	})
+
+	It("Should visit every edge reachable through an edge, walking shared nodes once", func() {
+		Expect(g.Walk("vim", "neovim", Required, visit)).To(Succeed())
+
+		Expect(visited).To(Equal([]string{
+			"vim -> neovim",
+			"neovim -> gettext",
+			"gettext -> readline",
+			"readline -> ncurses",
+			"neovim -> libuv",
+			"libuv -> readline",
+		}))
+		Expect(expanded).To(HaveKeyWithValue("readline", 1))
+	})
+
+	It("Should only follow edges of the kind walked", func() {
+		Expect(g.Walk("vim", "neovim", Build, visit)).To(Succeed())
+		Expect(visited).To(Equal([]string{"vim -> neovim", "neovim -> cmake"}))
+	})
+
+	It("Should remember the nodes walked between walks", func() {
+		Expect(g.Walk("vim", "neovim", Required, visit)).To(Succeed())
+		visited = nil
+
+		Expect(g.Walk("tmux", "libuv", Required, visit)).To(Succeed())
+		Expect(visited).To(Equal([]string{"tmux -> libuv"}))
+	})
+
+	It("Should report cycles with the path leading back to the node", func() {
+		edges[Required]["ncurses"] = []string{"gettext"}
+
+		Expect(g.Walk("vim", "neovim", Required, visit)).To(Equal(ErrCycle([]string{"gettext", "readline", "ncurses", "gettext"})))
+	})
+
+	It("Should return the errors from loading the edges of a node", func() {
+		edges[Required]["libuv"] = []string{"broken"}
+
+		Expect(g.Walk("vim", "neovim", Required, visit)).To(MatchError("broken"))
+	})
+})